@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// appIdentity names the GitHub App we're authenticated as, if any, in
+// place of a user login.
+var appIdentity string
+
+// appTokenSource provides installation access tokens for a GitHub App,
+// authenticating as the app with a JWT signed by its private key.
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	baseURL        string // API base URL, with trailing slash
+	client         *http.Client
+}
+
+func newAppTokenSource(appID, installationID int64, keyFile, baseURL string) (oauth2.TokenSource, error) {
+	bs, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, errors.New("no PEM data in private key file")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		k, err8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		rk, ok := k.(*rsa.PrivateKey)
+		if err8 != nil || !ok {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		key = rk
+	}
+	ts := &appTokenSource{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		baseURL:        baseURL,
+		client:         &http.Client{Timeout: time.Minute},
+	}
+	// Installation tokens are valid for an hour; reuse them until
+	// shortly before they expire.
+	return oauth2.ReuseTokenSource(nil, ts), nil
+}
+
+// jwt returns a JSON Web Token identifying the app, valid for a few
+// minutes.
+func (s *appTokenSource) jwt() (string, error) {
+	now := time.Now()
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(), // allow for clock skew
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	u := fmt.Sprintf("%sapp/installations/%d/access_tokens", s.baseURL, s.installationID)
+	req, err := http.NewRequest("POST", u, strings.NewReader("{}"))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		bs, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("getting installation token: %s: %s", resp.Status, bs)
+	}
+
+	var res struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{
+		AccessToken: res.Token,
+		// Refresh a few minutes early.
+		Expiry: res.ExpiresAt.Add(-5 * time.Minute),
+	}, nil
+}
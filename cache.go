@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"github.com/gregjones/httpcache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// pruneCache removes the least recently modified files in the directory
+// until the total size is at most max bytes.
+func pruneCache(dir string, max int64) error {
+	type entry struct {
+		path string
+		info fs.FileInfo
+	}
+	var files []entry
+	var total int64
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		files = append(files, entry{path, info})
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].info.ModTime().Before(files[b].info.ModTime())
+	})
+	for _, f := range files {
+		if total <= max {
+			break
+		}
+		if err := os.Remove(f.path); err != nil {
+			return err
+		}
+		total -= f.info.Size()
+	}
+	return nil
+}
+
+var metricHTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "freezebot",
+	Name:      "http_requests_total",
+	Help:      "Number of GitHub API requests, by whether they were answered from the cache (fresh or revalidated with a 304).",
+}, []string{"cache"})
+
+// revalidateTransport makes the cache revalidate every response before
+// using it. GitHub marks responses fresh for a minute, but our own writes
+// change what later requests in the run would see; a write only drops the
+// cached response for its own URL, not for the listings that include the
+// issue.
+type revalidateTransport struct {
+	next http.RoundTripper
+}
+
+func (t *revalidateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		req = req.Clone(req.Context())
+		req.Header.Set("Cache-Control", "max-age=0")
+	}
+	return t.next.RoundTrip(req)
+}
+
+// cacheStatsTransport counts requests answered from the HTTP cache, and
+// logs the totals at exit.
+type cacheStatsTransport struct {
+	next         http.RoundTripper
+	hits, misses int64
+}
+
+func (t *cacheStatsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Header.Get(httpcache.XFromCache) != "" {
+		atomic.AddInt64(&t.hits, 1)
+		metricHTTPRequests.WithLabelValues("hit").Inc()
+	} else {
+		atomic.AddInt64(&t.misses, 1)
+		metricHTTPRequests.WithLabelValues("miss").Inc()
+	}
+	return resp, nil
+}
+
+func (t *cacheStatsTransport) logStats() {
+	hits, misses := atomic.LoadInt64(&t.hits), atomic.LoadInt64(&t.misses)
+	if hits+misses > 0 {
+		log.Printf("HTTP cache: %d of %d requests answered from the cache (%.0f%%)", hits, hits+misses, 100*float64(hits)/float64(hits+misses))
+	}
+}
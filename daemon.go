@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"calmh.dev/freezebot/freeze"
+	"github.com/robfig/cron/v3"
+)
+
+// A job is an entry or campaign of the config, run on its schedule.
+type job struct {
+	name     string
+	key      string
+	schedule cron.Schedule
+	cfg      freeze.Config
+}
+
+// daemon runs each entry and campaign of the config on its schedule until
+// the context is cancelled. Jobs run one at a time, so they never overlap;
+// one that comes due while another is running waits its turn.
+func daemon(ctx context.Context, r *freeze.Runner, cfg freeze.Config) error {
+	var jobs []job
+	for _, e := range cfg.Entries {
+		if e.Schedule == "" {
+			return &freeze.ConfigError{Err: fmt.Errorf("entry %s has no schedule", e.Key())}
+		}
+		sched, _ := cron.ParseStandard(e.Schedule)
+		jobs = append(jobs, job{
+			name:     "entry " + e.Key(),
+			key:      e.Key(),
+			schedule: sched,
+			cfg:      jobConfig(cfg, []freeze.Entry{e}, nil),
+		})
+	}
+	for _, c := range cfg.Campaigns {
+		if c.Schedule == "" {
+			return &freeze.ConfigError{Err: fmt.Errorf("campaign %s has no schedule", c.Name)}
+		}
+		sched, _ := cron.ParseStandard(c.Schedule)
+		jobs = append(jobs, job{
+			name:     "campaign " + c.Name,
+			key:      "campaign:" + c.Name,
+			schedule: sched,
+			cfg:      jobConfig(cfg, nil, []freeze.Campaign{c}),
+		})
+	}
+	if len(jobs) == 0 {
+		return &freeze.ConfigError{Err: errors.New("nothing to schedule")}
+	}
+	if cfg.MaxActions > 0 {
+		log.Printf("The cap of %d actions applies to each run of an entry or campaign", cfg.MaxActions)
+	}
+
+	for {
+		// Find the job that is due first, counting from its last run. A
+		// job that has never run is due at its next scheduled time.
+		now := time.Now()
+		var next *job
+		var nextAt time.Time
+		for n := range jobs {
+			last, ok := r.State.LastRuns[jobs[n].key]
+			if !ok {
+				last = now
+			}
+			at := jobs[n].schedule.Next(last)
+			if next == nil || at.Before(nextAt) {
+				next, nextAt = &jobs[n], at
+			}
+		}
+
+		if wait := time.Until(nextAt); wait > 0 {
+			log.Printf("Next up is %s at %s", next.name, nextAt.Format(time.RFC3339))
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+
+		log.Printf("Running %s", next.name)
+		start := time.Now()
+		err := r.Run(ctx, next.cfg)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Printf("Running %s: %v", next.name, err)
+		} else {
+			log.Printf("Finished %s in %v", next.name, time.Since(start).Round(time.Second))
+		}
+		// Record the run as of when it started, so that a long run
+		// doesn't push the schedule.
+		r.State.LastRuns[next.key] = start
+		if err := r.State.Save(); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+}
+
+// jobConfig returns the config with only the given entries and campaigns,
+// keeping the global settings such as AllowedActions and MaxActions.
+func jobConfig(cfg freeze.Config, entries []freeze.Entry, campaigns []freeze.Campaign) freeze.Config {
+	cfg.Entries, cfg.Campaigns = entries, campaigns
+	return cfg
+}
@@ -0,0 +1,266 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHubSink performs the actions on GitHub.
+type GitHubSink struct {
+	Client *github.Client
+	// Audit, if set, receives each action once performed, including
+	// results such as the ID of created comments.
+	Audit ActionSink
+	// State, if set, records the issues we close.
+	State *State
+	// Clock, if set, dates the closes and locks recorded in the State, as
+	// the runner's Clock is what they are later compared against.
+	Clock Clock
+
+	mut         sync.Mutex
+	repoIDs     map[string]string // "owner/repo" -> GraphQL ID
+	categoryIDs map[string]string // "owner/repo:category" -> GraphQL ID
+	typeIDs     map[string]string // "owner:type" -> GraphQL ID
+	milestones  map[string]int    // "owner/repo:title" -> number
+}
+
+func (s *GitHubSink) Act(ctx context.Context, a Action) error {
+	var now time.Time
+	if s.Clock != nil {
+		now = s.Clock.Now()
+	} else {
+		now = time.Now()
+	}
+	var err error
+	switch a.Kind {
+	case ActionLabel:
+		infof("Labeling issue %d %q", a.Issue, a.Label)
+		err = labelIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue, a.Label)
+	case ActionUnlabel:
+		infof("Removing label %q from issue %d", a.Label, a.Issue)
+		err = unlabelIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue, a.Label)
+	case ActionComment:
+		infof("Commenting on issue %d", a.Issue)
+		a.CommentID, err = commentIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue, a.Comment)
+		if err == nil && a.Reaction != "" {
+			err = reactToComment(ctx, s.Client, a.Owner, a.Repo, a.Issue, a.CommentID, a.Reaction)
+		}
+	case ActionClose:
+		infof("Closing issue %d", a.Issue)
+		err = closeIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue)
+		if err == nil && s.State != nil {
+			s.State.with(func() { s.State.Closed[closedKey(a.Owner, a.Repo, a.Issue)] = now })
+		}
+	case ActionReopen:
+		infof("Reopening issue %d", a.Issue)
+		err = reopenIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue)
+		if err == nil && s.State != nil {
+			s.State.with(func() { delete(s.State.Closed, closedKey(a.Owner, a.Repo, a.Issue)) })
+		}
+	case ActionLock:
+		infof("Locking issue %d", a.Issue)
+		err = lockIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue, a.LockReason)
+		if err == nil && s.State != nil {
+			s.State.with(func() { s.State.Locked[closedKey(a.Owner, a.Repo, a.Issue)] = now })
+		}
+	case ActionUnlock:
+		infof("Unlocking issue %d", a.Issue)
+		err = unlockIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue)
+		if err == nil && s.State != nil {
+			s.State.with(func() { delete(s.State.Locked, closedKey(a.Owner, a.Repo, a.Issue)) })
+		}
+	case ActionTransfer:
+		infof("Transferring issue %d to %s", a.Issue, a.TransferTo)
+		err = s.transferIssue(ctx, a)
+	case ActionDraft:
+		infof("Converting pull request %d to a draft", a.Issue)
+		err = retry(ctx, "Converting to draft", a.Issue, func() error {
+			return graphQL(ctx, s.Client, `mutation($pr: ID!) {
+				convertPullRequestToDraft(input: {pullRequestId: $pr}) { pullRequest { number } }
+			}`, map[string]interface{}{"pr": a.IssueNodeID}, nil)
+		})
+	case ActionType:
+		infof("Setting type of issue %d to %q", a.Issue, a.IssueType)
+		err = s.setIssueType(ctx, a)
+	case ActionAssign:
+		infof("Assigning issue %d to %s", a.Issue, strings.Join(a.Assignees, ", "))
+		err = retry(ctx, "Assigning", a.Issue, func() error {
+			_, _, err := s.Client.Issues.AddAssignees(ctx, a.Owner, a.Repo, a.Issue, a.Assignees)
+			return err
+		})
+	case ActionUnassign:
+		infof("Unassigning %s from issue %d", strings.Join(a.Assignees, ", "), a.Issue)
+		err = retry(ctx, "Unassigning", a.Issue, func() error {
+			_, _, err := s.Client.Issues.RemoveAssignees(ctx, a.Owner, a.Repo, a.Issue, a.Assignees)
+			return err
+		})
+	case ActionAnswer, ActionDiscussionComment, ActionDiscussionLock:
+		err = s.actOnDiscussion(ctx, &a)
+	case ActionPin:
+		infof("Pinning issue %d", a.Issue)
+		err = retry(ctx, "Pinning", a.Issue, func() error {
+			return graphQL(ctx, s.Client, `mutation($issue: ID!) {
+				pinIssue(input: {issueId: $issue}) { issue { number } }
+			}`, map[string]interface{}{"issue": a.IssueNodeID}, nil)
+		})
+	case ActionUnpin:
+		infof("Unpinning issue %d", a.Issue)
+		err = retry(ctx, "Unpinning", a.Issue, func() error {
+			return graphQL(ctx, s.Client, `mutation($issue: ID!) {
+				unpinIssue(input: {issueId: $issue}) { issue { number } }
+			}`, map[string]interface{}{"issue": a.IssueNodeID}, nil)
+		})
+	case ActionMilestone:
+		infof("Setting milestone of issue %d to %q", a.Issue, a.Milestone)
+		err = s.setMilestone(ctx, a)
+	case ActionDiscussion:
+		infof("Converting issue %d to a discussion", a.Issue)
+		err = s.convertToDiscussion(ctx, &a)
+	default:
+		err = fmt.Errorf("unknown action %q", a.Kind)
+	}
+	if err != nil {
+		return err
+	}
+	if a.CommandID > 0 && s.State != nil {
+		key := closedKey(a.Owner, a.Repo, a.Issue)
+		s.State.with(func() {
+			if a.CommandID > s.State.Commands[key] {
+				s.State.Commands[key] = a.CommandID
+			}
+		})
+	}
+	metricActions.WithLabelValues(a.Owner, a.Repo, a.Directive, a.Kind).Inc()
+	if s.Audit != nil {
+		return s.Audit.Act(ctx, a)
+	}
+	return nil
+}
+
+func labelIssue(ctx context.Context, client *github.Client, owner, repo string, number int, label string) error {
+	return retry(ctx, "Adding label to", number, func() error {
+		_, _, err := client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{label})
+		return err
+	})
+}
+
+func unlabelIssue(ctx context.Context, client *github.Client, owner, repo string, number int, label string) error {
+	return retry(ctx, "Removing label from", number, func() error {
+		_, err := client.Issues.RemoveLabelForIssue(ctx, owner, repo, number, label)
+		return err
+	})
+}
+
+func lockIssue(ctx context.Context, client *github.Client, owner, repo string, number int, reason string) error {
+	var opts *github.LockIssueOptions
+	if reason != "" {
+		opts = &github.LockIssueOptions{LockReason: reason}
+	}
+	return retry(ctx, "Locking", number, func() error {
+		_, err := client.Issues.Lock(ctx, owner, repo, number, opts)
+		return err
+	})
+}
+
+func unlockIssue(ctx context.Context, client *github.Client, owner, repo string, number int) error {
+	return retry(ctx, "Unlocking", number, func() error {
+		_, err := client.Issues.Unlock(ctx, owner, repo, number)
+		return err
+	})
+}
+
+func closeIssue(ctx context.Context, client *github.Client, owner, repo string, number int) error {
+	return retry(ctx, "Closing", number, func() error {
+		_, _, err := client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{State: github.String("closed")})
+		return err
+	})
+}
+
+func reopenIssue(ctx context.Context, client *github.Client, owner, repo string, number int) error {
+	return retry(ctx, "Reopening", number, func() error {
+		_, _, err := client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{State: github.String("open")})
+		return err
+	})
+}
+
+func commentIssue(ctx context.Context, client *github.Client, owner, repo string, number int, comment string) (int64, error) {
+	var id int64
+	err := retry(ctx, "Commenting on", number, func() error {
+		c, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(comment)})
+		id = c.GetID()
+		return err
+	})
+	return id, err
+}
+
+func reactToComment(ctx context.Context, client *github.Client, owner, repo string, number int, id int64, content string) error {
+	return retry(ctx, "Reacting to comment on", number, func() error {
+		// Not in our version of the client library.
+		u := fmt.Sprintf("repos/%v/%v/issues/comments/%v/reactions", owner, repo, id)
+		req, err := client.NewRequest("POST", u, map[string]string{"content": content})
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "application/vnd.github.squirrel-girl-preview+json")
+		_, err = client.Do(ctx, req, nil)
+		return err
+	})
+}
+
+// retry calls fn until it succeeds, up to the number of retries. Errors that
+// retrying will not fix are returned immediately, as is the context error
+// if the context is cancelled while waiting to retry.
+func retry(ctx context.Context, what string, number int, fn func() error) error {
+	var err error
+	for i := 0; i < retries; i++ {
+		err = classifyAPIError(fn())
+		if err == nil || isFatal(err) {
+			break
+		}
+		warnf("%s issue %d: %v (retrying)\n", what, number, err)
+		select {
+		case <-time.After(time.Duration(i) * time.Second):
+		case <-ctx.Done():
+			return fmt.Errorf("%s issue %d: %w", strings.ToLower(what), number, ctx.Err())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s issue %d: %w", strings.ToLower(what), number, err)
+	}
+	return nil
+}
+
+func (s *GitHubSink) transferIssue(ctx context.Context, a Action) error {
+	repoID, err := s.repoID(ctx, a.TransferTo)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", a.TransferTo, err)
+	}
+	return retry(ctx, "Transferring", a.Issue, func() error {
+		return graphQL(ctx, s.Client, `mutation($issue: ID!, $repo: ID!) {
+			transferIssue(input: {issueId: $issue, repositoryId: $repo}) { issue { number } }
+		}`, map[string]interface{}{"issue": a.IssueNodeID, "repo": repoID}, nil)
+	})
+}
+
+func (s *GitHubSink) repoID(ctx context.Context, fullName string) (string, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if id, ok := s.repoIDs[fullName]; ok {
+		return id, nil
+	}
+	owner, name, _ := strings.Cut(fullName, "/")
+	repo, _, err := s.Client.Repositories.Get(ctx, owner, name)
+	if err != nil {
+		return "", classifyAPIError(err)
+	}
+	if s.repoIDs == nil {
+		s.repoIDs = make(map[string]string)
+	}
+	s.repoIDs[fullName] = repo.GetNodeID()
+	return repo.GetNodeID(), nil
+}
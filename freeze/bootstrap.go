@@ -0,0 +1,158 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Bootstrap seeds the state from what is already on GitHub, so that
+// enabling stateful directives on an existing deployment doesn't redo
+// earlier work: the issues closed and locked by the given user (us) are
+// recorded for Resurrect and UnlockAfterDays, and the comments already
+// present are marked as checked for SlashCommands. Existing state is kept.
+func (r *Runner) Bootstrap(ctx context.Context, cfg Config, me string) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	if r.State == nil {
+		return errors.New("bootstrapping needs a state store")
+	}
+
+	entries := cfg.Entries
+	for _, c := range cfg.Campaigns {
+		entries = append(entries, c.Entry)
+	}
+	for _, e := range entries {
+		var events bool
+		commandDays := 0
+		for _, d := range e.Directives {
+			if d.Resurrect != nil || d.UnlockAfterDays > 0 {
+				events = true
+			}
+			if d.SlashCommands != nil && d.SlashCommands.Days > commandDays {
+				commandDays = d.SlashCommands.Days
+			}
+		}
+		if !events && commandDays == 0 {
+			continue
+		}
+
+		repos, err := r.repoNames(ctx, e)
+		if err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			infof("Bootstrapping %s/%s", e.Owner, repo)
+			if events {
+				if err := r.bootstrapEvents(ctx, e.Owner, repo, me); err != nil {
+					return fmt.Errorf("%s/%s: %w", e.Owner, repo, err)
+				}
+			}
+			if commandDays > 0 {
+				since := r.Clock.Now().Add(-time.Duration(commandDays) * 24 * time.Hour)
+				if err := r.bootstrapComments(ctx, e.Owner, repo, since); err != nil {
+					return fmt.Errorf("%s/%s: %w", e.Owner, repo, err)
+				}
+			}
+			if err := r.State.Save(); err != nil {
+				return fmt.Errorf("saving state: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// bootstrapEvents records the issues that we closed or locked, and that
+// are still closed or locked, in the state.
+func (r *Runner) bootstrapEvents(ctx context.Context, owner, repo, me string) error {
+	type last struct {
+		event string
+		at    time.Time
+	}
+	closed := make(map[int]last)
+	locked := make(map[int]last)
+
+	opts := &github.ListOptions{PerPage: perPage}
+	for {
+		evs, resp, err := r.Client.Issues.ListRepositoryEvents(ctx, owner, repo, opts)
+		if err != nil {
+			return classifyAPIError(err)
+		}
+		for _, ev := range evs {
+			n := ev.GetIssue().GetNumber()
+			var m map[int]last
+			switch ev.GetEvent() {
+			case "closed", "reopened":
+				m = closed
+			case "locked", "unlocked":
+				m = locked
+			default:
+				continue
+			}
+			if prev, ok := m[n]; ok && prev.at.After(ev.GetCreatedAt()) {
+				continue
+			}
+			event := ev.GetEvent()
+			if ev.GetActor().GetLogin() != me {
+				// Closed or locked by someone else is as good as not by
+				// us.
+				event = "other"
+			}
+			m[n] = last{event, ev.GetCreatedAt()}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	added := 0
+	for n, l := range closed {
+		key := closedKey(owner, repo, n)
+		if _, ok := r.State.Closed[key]; !ok && l.event == "closed" {
+			r.State.Closed[key] = l.at
+			added++
+		}
+	}
+	for n, l := range locked {
+		key := closedKey(owner, repo, n)
+		if _, ok := r.State.Locked[key]; !ok && l.event == "locked" {
+			r.State.Locked[key] = l.at
+			added++
+		}
+	}
+	infof("Recorded %d issues closed or locked by %s", added, me)
+	return nil
+}
+
+// bootstrapComments marks the comments made since the given time as checked
+// for slash commands, so that old commands aren't performed.
+func (r *Runner) bootstrapComments(ctx context.Context, owner, repo string, since time.Time) error {
+	opts := &github.IssueListCommentsOptions{Since: since, ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := r.Client.Issues.ListComments(ctx, owner, repo, 0, opts)
+		if err != nil {
+			return classifyAPIError(err)
+		}
+		for _, c := range comments {
+			n, err := strconv.Atoi(path.Base(c.GetIssueURL()))
+			if err != nil {
+				continue
+			}
+			key := closedKey(owner, repo, n)
+			if c.GetID() > r.State.Commands[key] {
+				r.State.Commands[key] = c.GetID()
+			}
+		}
+		if resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,142 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Campaign is a one-off set of directives, such as a backfill, active
+// between its start and end dates. Once a run has gone through all of its
+// repos without failures or leftover checkpoints, the campaign is recorded
+// as complete in the state and not run again.
+type Campaign struct {
+	Name string
+	// Start and End are dates ("2006-01-02") or RFC 3339 timestamps; either
+	// may be empty for no limit.
+	Start string
+	End   string
+	// BatchSize, if set, is the most issues the campaign acts on per run,
+	// and BatchPause a duration ("5s") to wait after each, to spread large
+	// migrations over time and stay clear of secondary rate limits.
+	BatchSize  int
+	BatchPause string
+	Entry
+
+	start, end time.Time
+}
+
+func (c *Campaign) validate() error {
+	if c.Name == "" {
+		return errors.New("every campaign must set `name`")
+	}
+	var err error
+	if c.start, err = parseTime(c.Start); err != nil {
+		return fmt.Errorf("campaign %s: start: %w", c.Name, err)
+	}
+	if c.end, err = parseTime(c.End); err != nil {
+		return fmt.Errorf("campaign %s: end: %w", c.Name, err)
+	}
+	var batchPause time.Duration
+	if c.BatchPause != "" {
+		if batchPause, err = time.ParseDuration(c.BatchPause); err != nil {
+			return fmt.Errorf("campaign %s: batchPause: %w", c.Name, err)
+		}
+	}
+	c.Entry.setDefaults()
+	for i := range c.Directives {
+		c.Directives[i].scope = "campaign:" + c.Name
+		c.Directives[i].capKey = fmt.Sprintf("campaign:%s/%d", c.Name, i)
+		c.Directives[i].batchSize = c.BatchSize
+		c.Directives[i].batchPause = batchPause
+	}
+	if err := c.Entry.validate(); err != nil {
+		return fmt.Errorf("campaign %s: %w", c.Name, err)
+	}
+	return nil
+}
+
+// active returns true if the campaign should run at the given time.
+func (c Campaign) active(now time.Time, st *State) bool {
+	if _, done := st.Campaigns[c.Name]; done {
+		return false
+	}
+	if !c.start.IsZero() && now.Before(c.start) {
+		return false
+	}
+	if !c.end.IsZero() && !now.Before(c.end) {
+		return false
+	}
+	return true
+}
+
+// finished returns true if there are no checkpoints left for the campaign.
+func (c Campaign) finished(st *State) bool {
+	prefix := "campaign:" + c.Name + "/"
+	for key := range st.Checkpoints {
+		if strings.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Parse("2006-01-02", s)
+	}
+	return t, nil
+}
+
+// batches counts the issues acted on per campaign during a run, for
+// campaigns with a batch size.
+type batches struct {
+	mut    sync.Mutex
+	counts map[string]int
+}
+
+func (b *batches) reset() {
+	b.mut.Lock()
+	b.counts = nil
+	b.mut.Unlock()
+}
+
+// take counts an issue for the directive's campaign, returning false if the
+// batch is already full.
+func (b *batches) take(d Directive) bool {
+	if d.batchSize <= 0 {
+		return true
+	}
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	if b.counts[d.scope] >= d.batchSize {
+		return false
+	}
+	if b.counts == nil {
+		b.counts = make(map[string]int)
+	}
+	b.counts[d.scope]++
+	return true
+}
+
+// batchPause waits for the directive's batch pause, if any.
+func batchPause(ctx context.Context, d Directive) error {
+	if d.batchPause <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d.batchPause)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
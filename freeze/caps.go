@@ -0,0 +1,46 @@
+package freeze
+
+import "sync"
+
+// caps counts the actions taken during a run, against the global and
+// per directive MaxActions.
+type caps struct {
+	mut    sync.Mutex
+	max    int
+	total  int
+	counts map[string]int // Directive.capKey -> actions
+}
+
+func (c *caps) reset(max int) {
+	c.mut.Lock()
+	c.max, c.total, c.counts = max, 0, nil
+	c.mut.Unlock()
+}
+
+// full returns true if no more actions may be taken for the directive.
+func (c *caps) full(d Directive) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.max > 0 && c.total >= c.max || d.MaxActions > 0 && c.counts[d.capKey] >= d.MaxActions
+}
+
+// take counts n actions for the directive, returning false without
+// counting them if that would exceed a cap. The actions for the first issue
+// are always taken, so that an issue needing more actions than the cap
+// doesn't block everything behind it.
+func (c *caps) take(d Directive, n int) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.total > 0 && c.max > 0 && c.total+n > c.max {
+		return false
+	}
+	if cur := c.counts[d.capKey]; cur > 0 && d.MaxActions > 0 && cur+n > d.MaxActions {
+		return false
+	}
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.total += n
+	c.counts[d.capKey] += n
+	return true
+}
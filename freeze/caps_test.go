@@ -0,0 +1,57 @@
+package freeze
+
+import "testing"
+
+func TestCapsTake(t *testing.T) {
+	a := Directive{capKey: "0/0", MaxActions: 3}
+	b := Directive{capKey: "0/1"}
+
+	var c caps
+	c.reset(5)
+	steps := []struct {
+		d    Directive
+		n    int
+		want bool
+	}{
+		{a, 2, true},
+		{a, 2, false}, // would be 4 of a's 3
+		{a, 1, true},
+		{a, 1, false}, // a is full
+		{b, 3, false}, // would be 6 of the 5
+		{b, 2, true},
+		{b, 1, false}, // all full
+	}
+	for n, s := range steps {
+		if got := c.take(s.d, s.n); got != s.want {
+			t.Fatalf("step %d: take(%s, %d) = %v, want %v", n, s.d.capKey, s.n, got, s.want)
+		}
+	}
+	if !c.full(a) || !c.full(b) {
+		t.Error("caps not full")
+	}
+}
+
+func TestCapsTakeFirstAlways(t *testing.T) {
+	// The first issue's actions are taken even when more than the caps, so
+	// that it doesn't block everything behind it.
+	d := Directive{capKey: "0/0", MaxActions: 1}
+	var c caps
+	c.reset(2)
+	if !c.take(d, 3) {
+		t.Fatal("first actions not taken")
+	}
+	if c.take(d, 1) {
+		t.Error("actions taken beyond the caps")
+	}
+
+	c.reset(0)
+	if c.full(d) {
+		t.Error("full after reset")
+	}
+	if !c.take(d, 1) || c.take(d, 1) {
+		t.Error("directive cap not applied without a global cap")
+	}
+	if c.full(Directive{capKey: "0/1"}) {
+		t.Error("other directive full without a global cap")
+	}
+}
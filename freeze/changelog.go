@@ -0,0 +1,107 @@
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// policy flattens the effective configuration into a map from "entry:
+// directive: field" to the JSON encoded value of each field that is set.
+func policy(cfg Config) (map[string]string, error) {
+	p := make(map[string]string)
+	addEntry := func(prefix string, e Entry) error {
+		for n, d := range e.Directives {
+			name := d.Name
+			if name == "" {
+				name = fmt.Sprintf("directive %d", n)
+			}
+			bs, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(bs, &fields); err != nil {
+				return err
+			}
+			for field, val := range fields {
+				switch string(val) {
+				case "null", "false", "0", `""`, "[]", "{}":
+					continue
+				}
+				p[fmt.Sprintf("%s: %s: %s", prefix, name, field)] = string(val)
+			}
+		}
+		return nil
+	}
+	for _, e := range cfg.Entries {
+		if err := addEntry(e.Key(), e); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range cfg.Campaigns {
+		if err := addEntry("campaign "+c.Name, c.Entry); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// policyChanges describes the differences between two policies, one line
+// per changed field.
+func policyChanges(old, cur map[string]string) []string {
+	var lines []string
+	for key, val := range cur {
+		if prev, ok := old[key]; !ok {
+			lines = append(lines, fmt.Sprintf("- `%s` set to `%s`", key, val))
+		} else if prev != val {
+			lines = append(lines, fmt.Sprintf("- `%s` changed from `%s` to `%s`", key, prev, val))
+		}
+	}
+	for key, val := range old {
+		if _, ok := cur[key]; !ok {
+			lines = append(lines, fmt.Sprintf("- `%s` removed (was `%s`)", key, val))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// Changelog posts a summary of how the configuration changed since the
+// last run to the config's ChangelogIssue, if set. The first run only
+// records the configuration.
+func (r *Runner) Changelog(ctx context.Context, cfg Config) error {
+	if cfg.ChangelogIssue == "" || r.State == nil {
+		return nil
+	}
+	cur, err := policy(cfg)
+	if err != nil {
+		return err
+	}
+	if r.State.Policy == nil {
+		infof("Recording configuration for the changelog")
+		r.State.Policy = cur
+		return r.State.Save()
+	}
+	lines := policyChanges(r.State.Policy, cur)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	t := cfg.changelogIssue
+	a := Action{
+		Owner:     t.owner,
+		Repo:      t.repo,
+		Issue:     t.number,
+		Directive: "changelog",
+		Kind:      ActionComment,
+		Comment:   fmt.Sprintf("The freezebot configuration changed:\n\n%s\n", strings.Join(lines, "\n")),
+	}
+	if err := r.sink().Act(ctx, a); err != nil {
+		return fmt.Errorf("posting changelog to %s: %w", t, err)
+	}
+	r.State.Policy = cur
+	return r.State.Save()
+}
@@ -0,0 +1,20 @@
+package freeze
+
+import "time"
+
+// A Clock tells the current time. Everything that compares issue timestamps
+// against "now" goes through one, so that a run can be evaluated as of any
+// given moment.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the system clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time { return time.Now() }
+
+// FixedClock is always at the same moment.
+type FixedClock time.Time
+
+func (c FixedClock) Now() time.Time { return time.Time(c) }
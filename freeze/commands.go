@@ -0,0 +1,270 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// commandPrefix starts the comment commands we recognize from maintainers:
+//
+//	/freezebot ignore       never act on the issue
+//	/freezebot snooze 90d   don't act on the issue for 90 days
+const commandPrefix = "/freezebot"
+
+// snoozed returns true if a maintainer told us to leave the issue alone,
+// now or in an earlier run. Commands found are recorded in the state.
+func (r *Runner) snoozed(ctx context.Context, owner, repo string, number int) (bool, error) {
+	key := closedKey(owner, repo, number)
+	now := r.Clock.Now()
+	var until time.Time
+	var ok bool
+	r.State.with(func() { until, ok = r.State.Snoozed[key] })
+	if ok && (until.IsZero() || until.After(now)) {
+		return true, nil
+	}
+
+	var found bool
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := r.Client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return false, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			d, ok := parseCommand(c.GetBody())
+			if !ok {
+				continue
+			}
+			member, err := r.isMember(ctx, owner, repo, c.GetUser().GetLogin())
+			if err != nil {
+				return false, err
+			}
+			if !member {
+				continue
+			}
+			// The latest command wins.
+			found = true
+			until = time.Time{}
+			if d > 0 {
+				until = c.GetCreatedAt().Add(d)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if !found {
+		return false, nil
+	}
+	r.State.with(func() { r.State.Snoozed[key] = until })
+	return until.IsZero() || until.After(now), nil
+}
+
+// parseCommand looks for a command line in the comment, returning the
+// snooze duration, zero for ignore.
+func parseCommand(body string) (time.Duration, bool) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != commandPrefix {
+			continue
+		}
+		switch fields[1] {
+		case "ignore":
+			return 0, true
+		case "snooze":
+			if len(fields) < 3 {
+				continue
+			}
+			d, err := parseDays(fields[2])
+			if err != nil || d <= 0 {
+				continue
+			}
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// parseDays parses durations like "90d" and "2w" in addition to the
+// standard Go ones.
+func parseDays(s string) (time.Duration, error) {
+	unit := 24 * time.Hour
+	switch {
+	case strings.HasSuffix(s, "w"):
+		unit *= 7
+		fallthrough
+	case strings.HasSuffix(s, "d"):
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	default:
+		return time.ParseDuration(s)
+	}
+}
+
+// SlashCommands configures a directive that performs the slash commands
+// (/close, /lock, /reopen, /label name) that authorized users leave in
+// comments.
+type SlashCommands struct {
+	// Commands lists the commands to accept; all of them by default.
+	Commands []string
+	// Teams, if set, lists the "org/team" teams whose members may use the
+	// commands. By default org members and repo collaborators may.
+	Teams []string
+	// Days is how far back to look for commands; one day by default.
+	Days int
+}
+
+var slashCommands = map[string]string{
+	"/close":  ActionClose,
+	"/lock":   ActionLock,
+	"/reopen": ActionReopen,
+	"/label":  ActionLabel,
+}
+
+func (s *SlashCommands) validate() error {
+	for _, c := range s.Commands {
+		if _, ok := slashCommands[c]; !ok {
+			return fmt.Errorf("unknown slash command %q", c)
+		}
+	}
+	for _, team := range s.Teams {
+		if strings.Count(team, "/") != 1 {
+			return fmt.Errorf("slash command team %q is not \"org/team\"", team)
+		}
+	}
+	if s.Days == 0 {
+		s.Days = 1
+	}
+	return nil
+}
+
+func (s *SlashCommands) allows(cmd string) bool {
+	if len(s.Commands) == 0 {
+		return true
+	}
+	for _, c := range s.Commands {
+		if c == cmd {
+			return true
+		}
+	}
+	return false
+}
+
+// slashActions returns the actions for the commands in comments on the
+// issue that are newer than the last one we handled. The comments count as
+// handled once the last of the actions has been performed, so that failed
+// or postponed commands are tried again next run.
+func (r *Runner) slashActions(ctx context.Context, base Action, i github.Issue, sc *SlashCommands) ([]Action, error) {
+	key := closedKey(base.Owner, base.Repo, base.Issue)
+	since := r.Clock.Now().Add(-time.Duration(sc.Days) * 24 * time.Hour)
+	var seen int64
+	r.State.with(func() { seen = r.State.Commands[key] })
+	last := seen
+
+	var actions []Action
+	state := i.GetState()
+	labels := make(map[string]bool)
+	for _, l := range i.Labels {
+		labels[l.GetName()] = true
+	}
+
+	opts := &github.IssueListCommentsOptions{Since: since, ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := r.Client.Issues.ListComments(ctx, base.Owner, base.Repo, base.Issue, opts)
+		if err != nil {
+			return nil, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			if c.GetID() <= seen || c.GetCreatedAt().Before(since) {
+				continue
+			}
+			if c.GetID() > last {
+				last = c.GetID()
+			}
+
+			ok, err := r.mayCommand(ctx, base.Owner, base.Repo, c.GetUser().GetLogin(), sc)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+
+			for _, line := range strings.Split(c.GetBody(), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 0 || !sc.allows(fields[0]) {
+					continue
+				}
+				kind, ok := slashCommands[fields[0]]
+				if !ok {
+					continue
+				}
+				a := base
+				a.Kind = kind
+				switch kind {
+				case ActionClose:
+					if state == "closed" {
+						continue
+					}
+					state = "closed"
+				case ActionReopen:
+					if state != "closed" {
+						continue
+					}
+					state = "open"
+				case ActionLabel:
+					if len(fields) < 2 {
+						continue
+					}
+					a.Label = strings.Join(fields[1:], " ")
+					if labels[a.Label] {
+						continue
+					}
+					labels[a.Label] = true
+				}
+				actions = append(actions, a)
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	switch {
+	case last == seen:
+	case len(actions) == 0:
+		// Nothing to do, and so nothing to try again.
+		r.State.with(func() { r.State.Commands[key] = last })
+	default:
+		actions[len(actions)-1].CommandID = last
+	}
+	return actions, nil
+}
+
+// mayCommand returns true if the user is allowed to use slash commands.
+func (r *Runner) mayCommand(ctx context.Context, owner, repo, login string, sc *SlashCommands) (bool, error) {
+	if len(sc.Teams) == 0 {
+		return r.isMember(ctx, owner, repo, login)
+	}
+	for _, team := range sc.Teams {
+		set, err := r.teamMembers(ctx, team)
+		if err != nil {
+			return false, fmt.Errorf("listing team %s: %w", team, err)
+		}
+		if set[strings.ToLower(login)] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
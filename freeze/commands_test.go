@@ -0,0 +1,58 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCommand(t *testing.T) {
+	cases := []struct {
+		body string
+		want time.Duration
+		ok   bool
+	}{
+		{"/freezebot ignore", 0, true},
+		{"Thanks!\n\n/freezebot snooze 90d\n", 90 * 24 * time.Hour, true},
+		{"/freezebot snooze 2w", 14 * 24 * time.Hour, true},
+		{"/freezebot snooze 36h", 36 * time.Hour, true},
+		{"  /freezebot   ignore  please", 0, true},
+		// The first valid command wins.
+		{"/freezebot snooze\n/freezebot snooze 1d\n/freezebot ignore", 24 * time.Hour, true},
+		{"/freezebot snooze", 0, false},
+		{"/freezebot snooze 0d", 0, false},
+		{"/freezebot snooze -5d", 0, false},
+		{"/freezebot snooze soon", 0, false},
+		{"/freezebot", 0, false},
+		{"/freezebot unknown", 0, false},
+		{"please /freezebot ignore", 0, false},
+		{"/freezebotignore", 0, false},
+		{"", 0, false},
+	}
+	for _, tc := range cases {
+		got, ok := parseCommand(tc.body)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("parseCommand(%q) = %v, %v; want %v, %v", tc.body, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestParseDays(t *testing.T) {
+	cases := []struct {
+		s    string
+		want time.Duration
+		err  bool
+	}{
+		{"1d", 24 * time.Hour, false},
+		{"3w", 21 * 24 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"d", 0, true},
+		{"xw", 0, true},
+		{"5", 0, true},
+	}
+	for _, tc := range cases {
+		got, err := parseDays(tc.s)
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("parseDays(%q) = %v, %v; want %v, error %v", tc.s, got, err, tc.want, tc.err)
+		}
+	}
+}
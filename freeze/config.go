@@ -0,0 +1,816 @@
+// Package freeze decides which issues need labeling, closing or locking
+// according to a set of directives, and performs those actions.
+package freeze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/expr-lang/expr/vm"
+	"github.com/robfig/cron/v3"
+	"go.starlark.net/starlark"
+)
+
+// Config is a freezebot configuration, as read from a config file. The
+// file is either an object with the fields below, or just the list of
+// entries.
+type Config struct {
+	// AllowedActions, if set, limits the actions that will be performed
+	// to the given kinds, regardless of what the directives ask for.
+	AllowedActions []string
+	// BaseURL is the API base URL for GitHub Enterprise Server, e.g.
+	// "https://ghe.example.com/api/v3/", and UploadURL the corresponding
+	// upload URL, by default derived from BaseURL.
+	BaseURL   string
+	UploadURL string
+	Entries   []Entry
+	// Campaigns are one-off sets of directives, run in addition to the
+	// entries until complete.
+	Campaigns []Campaign
+	// MaxActions, if set, is the most actions to take per run, over all
+	// entries and campaigns. Issues beyond it are left for the next run.
+	MaxActions int
+	// State is where to keep state across runs (see OpenStore), unless
+	// given on the command line.
+	State string
+	// ChangelogIssue, if set, is an "owner/repo#number" issue that gets a
+	// comment summarizing the changes whenever the directives change.
+	ChangelogIssue string
+	changelogIssue summaryTarget
+	// RequireYes makes every run a dry run unless -yes is given, for
+	// deployments where nothing may change by default.
+	RequireYes bool
+}
+
+func (c *Config) UnmarshalJSON(bs []byte) error {
+	if bs = bytes.TrimSpace(bs); len(bs) > 0 && bs[0] == '[' {
+		return json.Unmarshal(bs, &c.Entries)
+	}
+	type plain Config
+	return json.Unmarshal(bs, (*plain)(c))
+}
+
+// Entry applies a set of directives to some or all repos of an owner.
+type Entry struct {
+	Owner string
+	// Repos lists the repos to process; if empty, all repos of the owner
+	// are processed.
+	Repos      []string
+	Directives []Directive
+	// Discussions are directives for the repos' discussions.
+	Discussions []DiscussionDirective
+	// Schedule is a cron expression ("0 3 * * *") saying when to run the
+	// entry in daemon mode.
+	Schedule string
+	// Housekeeping, if set, is "release" or an issue "#number" whose
+	// description gets a list of the issues we closed since the latest
+	// release, by the housekeeping command.
+	Housekeeping string
+	// CloseComment and WarnComment are defaults for the directives of the
+	// entry that don't set their own: CloseComment for those that close,
+	// and WarnComment for those that close or lock, set a Label and ask
+	// for a warning stage by setting DaysAfterWarn. Directives without
+	// DaysAfterWarn act right away, as if there were no WarnComment.
+	CloseComment string
+	WarnComment  string
+	// Labels is the canonical label set of the entry's repos, created and
+	// kept up to date by the sync-labels command.
+	Labels []LabelSpec
+	// OrgSearch, for entries without Repos, runs the query of each query
+	// directive once over the whole owner, and the directive only in the
+	// repos where it has matches. It saves requests when matches are
+	// sparse over many repos.
+	OrgSearch bool
+}
+
+// An AgeLabel is a rung of a Directive's AgeLabels.
+type AgeLabel struct {
+	Label string
+	Days  int
+}
+
+// Directive selects issues and says what to do with them.
+type Directive struct {
+	// Name identifies the directive in logs and metrics. It defaults to
+	// the index of the directive within the config entry.
+	Name string
+	// scope is set for directives that are part of a campaign, as are the
+	// batch settings of the campaign.
+	scope      string
+	batchSize  int
+	batchPause time.Duration
+	capKey     string
+	// orgRepos, if set, are the only repos the directive has matches in,
+	// per an org search.
+	orgRepos map[string]bool
+
+	// MaxActions, if set, is the most actions the directive takes per run,
+	// over all repos of the entry. Issues beyond it are left for the next
+	// run.
+	MaxActions int
+
+	Query string
+	State string
+	// ItemType is "issue" or "pr" to apply the directive to only issues
+	// or pull requests, or "both" (the default).
+	ItemType   string
+	DaysClosed int
+	// DaysClosedByHuman, if set, replaces DaysClosed for issues closed by
+	// a person rather than by us or another bot, e.g. to lock what we
+	// closed right away but give people time to react to other closings.
+	// Ours are known from the state, others from the issue's timeline.
+	DaysClosedByHuman int
+	DaysNotUpdated    int
+	Label             string
+	// RemoveLabels are removed from matching issues that have them.
+	RemoveLabels []string
+	// AgeLabels, if set, keeps one of the labels on open matching issues:
+	// the one with the most Days up to the age of the issue, removing the
+	// others. The age is counted from creation, as our own label changes
+	// count as updates.
+	AgeLabels []AgeLabel
+	Lock      bool
+	// Draft converts matching pull requests to drafts.
+	Draft bool
+	// Pin and Unpin pin matching issues to, or unpin them from, the repo's
+	// issue list. A repo has at most three pinned issues.
+	Pin   bool
+	Unpin bool
+	// Unlock unlocks matching issues, which are then locked ones only, and
+	// DaysLocked limits those to issues locked at least that long ago, by
+	// anyone. See UnlockAfterDays for unlocking only what we locked.
+	Unlock     bool
+	DaysLocked int
+	// LockSummary, if set, makes us comment with a summary of the thread
+	// before locking.
+	LockSummary *LockSummary
+	// LockReason, if set, is "resolved", "off-topic", "too heated" or
+	// "spam", shown on issues we lock.
+	LockReason string
+	Close      bool
+	// CloseComment is a text/template, with access to the issue as
+	// {{.Number}}, {{.Author}}, {{.DaysSinceUpdate}}, {{.Labels}} etc. (see
+	// commentData), and to repo metadata as {{.Repo.HTMLURL}},
+	// {{.Repo.DefaultBranch}}, {{.Repo.LatestRelease}}, etc.
+	CloseComment string
+	closeComment *template.Template
+	// CloseCommentByAssociation overrides CloseComment by the author's
+	// association with the repo, e.g. "FIRST_TIME_CONTRIBUTOR",
+	// "CONTRIBUTOR" or "NONE".
+	CloseCommentByAssociation map[string]string
+	closeCommentByAssociation map[string]*template.Template
+
+	// WarnComment, if set, makes closing and locking a two stage process:
+	// matching issues first get the comment and the Label, and only once
+	// DaysAfterWarn more days have passed without activity are they closed
+	// and locked. Issues with activity after the warning lose the Label
+	// instead. It's a template like CloseComment, and requires a Label.
+	WarnComment   string
+	warnComment   *template.Template
+	DaysAfterWarn int
+	// GraceDays, if set, gives the author of a warned pull request that
+	// many more days when they reply to the warning, instead of the Label
+	// being removed. Each pull request gets the grace once; other activity
+	// during it doesn't count.
+	GraceDays int
+
+	// HonorCommands makes us look for "/freezebot ignore" and
+	// "/freezebot snooze 90d" comments from org members or collaborators,
+	// and skip issues accordingly.
+	HonorCommands bool
+
+	// ExemptMembers skips issues opened by members of the owning org or
+	// collaborators on the repo.
+	ExemptMembers bool
+	// ExemptAuthors and ExemptAssignees skip issues opened by or assigned
+	// to any of the listed users. Entries may also be teams, as "@team" in
+	// the owning org or "@org/team".
+	ExemptAuthors   []string
+	ExemptAssignees []string
+	// ExemptIfCommentedByTeam lists "org/team" teams; issues where a team
+	// member commented within the DaysNotUpdated (or DaysClosed) period
+	// are skipped.
+	ExemptIfCommentedByTeam []string
+
+	// ExemptMilestones skips issues in any of the named open milestones,
+	// or in any open milestone at all if it includes "any".
+	ExemptMilestones []string
+
+	// ExemptIfReferencedDays, if set, skips issues referenced by open
+	// issues or pull requests in other repos within this many days, as
+	// interest elsewhere counts as activity.
+	ExemptIfReferencedDays int
+
+	// NoCloseFor lists kinds of authors whose issues are labeled but not
+	// closed: "contributors" (with commits in the repo) and/or "sponsors"
+	// (of the owner).
+	NoCloseFor []string
+
+	// TrackedTasks controls what happens when an issue to be closed is an
+	// unchecked task in another open issue: "skip" to not close it, or
+	// "flag" to add the TrackedTaskLabel instead of closing it. By default
+	// we don't check.
+	TrackedTasks     string
+	TrackedTaskLabel string
+
+	// TransferTo, if set, is the repo to move matching issues to, as
+	// "owner/repo" or just "repo" for one of the same owner. GitHub only
+	// transfers issues between repos of the same owner. It can't be
+	// combined with Close or Lock, as the issue is no longer here
+	// afterwards. Pull requests can't be transferred and are skipped.
+	TransferTo string
+	// TransferComment, if set, is a comment template like CloseComment
+	// explaining the move, made before transferring.
+	TransferComment string
+	transferComment *template.Template
+
+	// Patterns, if set, are regular expressions of which at least one must
+	// match the title or body of an issue for the directive to apply, e.g.
+	// to find support questions among bug reports. They're case
+	// insensitive.
+	Patterns []string
+	patterns []*regexp.Regexp
+
+	// IssueType, if set, is the organization issue type ("Bug", "Task",
+	// ...) to give matching issues, and IssueTypeByLabel the type to give
+	// them by label, for the first label of the issue found in it. Issues
+	// that already have a type are left alone.
+	IssueType        string
+	IssueTypeByLabel map[string]string
+
+	// Unassign removes all assignees from matching issues. Assign, if set,
+	// lists users of which one is assigned to matching issues that have no
+	// other assignees, rotating by issue number, e.g. a triage rotation.
+	Unassign bool
+	Assign   []string
+
+	// SetMilestone, if set, is the title of an open milestone to move
+	// matching issues to, e.g. "Backlog".
+	SetMilestone string
+
+	// ConvertToDiscussion, if set, is the discussion category to move
+	// matching issues to: a discussion is created from the issue, which
+	// gets a comment linking to it and is then closed and locked, as when
+	// converting on GitHub. It implies Close, Lock and an ItemType of
+	// "issue", as pull requests can't be converted.
+	ConvertToDiscussion string
+
+	// CommentReaction, if set, is a reaction ("eyes", "+1", ...) that we
+	// add to our own comments, to seed feedback on them.
+	CommentReaction string
+	// FeedbackURL, if set, is linked at the end of our comments.
+	FeedbackURL string
+
+	// SummaryIssue, if set, is an "owner/repo#number" issue that gets a
+	// single comment at the end of each run listing the issues the
+	// directive closed, in place of commenting on each of them.
+	SummaryIssue string
+	summaryIssue summaryTarget
+
+	// Quiet drops the comments the directive would otherwise make, to
+	// avoid notifying everyone involved during large cleanups.
+	// QuietLabels, with Quiet, drops labels as well, as label changes too
+	// notify some watchers and tools.
+	Quiet       bool
+	QuietLabels bool
+
+	// Workers, if more than one, is the number of issues to perform
+	// actions on concurrently. Actions on the same issue are always
+	// performed in order.
+	Workers int
+
+	// Threshold is "atLeast" (the default) to act when the day count
+	// reaches the configured number of days, or "moreThan" to act only
+	// once it's exceeded.
+	Threshold string
+	// DayCounting is "elapsed" (the default) to count full 24 hour periods,
+	// "calendar" to count calendar date changes in the local time zone, or
+	// "business" to count only the weekdays among those.
+	DayCounting string
+	// FreezeWindows are periods that don't count as days at all.
+	FreezeWindows []FreezeWindow
+	// Activity is "any" (the default) to count days since the issue was
+	// last updated, or "human" to count days since it was last commented on
+	// by someone other than a bot, making DaysNotUpdated ignore our own
+	// comments and those of other bots.
+	Activity string
+	// NeedsInfo, if set, is a label like "needs-info" that limits the
+	// directive to issues that have it, counting DaysNotUpdated from when
+	// it was last added, or from when the author last commented after
+	// that.
+	NeedsInfo string
+
+	// When is an optional expression that must evaluate to true for the
+	// directive to apply, e.g. `daysSince(updated) > 90 && comments < 3 &&
+	// !hasLabel("pinned")`. Using the event functions, as in
+	// `daysSince(labeledAt("triage")) > 14` or `lastActor("closed") ==
+	// author`, fetches the issue's timeline (see whenEnv).
+	When       string
+	when       *vm.Program
+	whenEvents bool
+
+	// Resurrect, if set, makes this a directive that reopens or labels
+	// issues we closed, which drew interest after closing, in place of
+	// selecting issues by query. It requires a state file.
+	Resurrect *Resurrect
+
+	// UnlockAfterDays, if set, makes this a directive that unlocks issues
+	// we locked at least this many days ago, in place of selecting issues
+	// by query. It requires a state file.
+	UnlockAfterDays int
+
+	// SlashCommands, if set, makes the directive perform the slash
+	// commands left by authorized users on matching issues, in place of
+	// the action fields above.
+	SlashCommands *SlashCommands
+
+	// EOL, if set, makes the directive act on issues reporting versions
+	// older than the minimum supported one, in place of the action fields
+	// above.
+	EOL *EOL
+
+	// Script is an optional Starlark file defining a function
+	// decide(issue) that returns the list of actions to take on a
+	// matching issue, in place of the action fields above.
+	Script string
+	script *starlark.Function
+
+	// Plugin is an optional WebAssembly module deciding the actions, as an
+	// alternative to Script. See the plugin type for the interface.
+	Plugin string
+	plugin *plugin
+}
+
+const (
+	thresholdAtLeast    = "atLeast"
+	thresholdMoreThan   = "moreThan"
+	dayCountingElapsed  = "elapsed"
+	dayCountingCalendar = "calendar"
+	dayCountingBusiness = "business"
+	activityAny         = "any"
+	activityHuman       = "human"
+	itemBoth            = "both"
+	itemIssue           = "issue"
+	itemPR              = "pr"
+	trackedTasksSkip    = "skip"
+	trackedTasksFlag    = "flag"
+	authorContributors  = "contributors"
+	authorSponsors      = "sponsors"
+)
+
+// quiet returns the actions without those that Quiet drops.
+func (d Directive) quiet(actions []Action) []Action {
+	res := actions[:0]
+	for _, a := range actions {
+		if a.Kind == ActionComment || (a.Kind == ActionLabel || a.Kind == ActionUnlabel) && d.QuietLabels {
+			continue
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// Key identifies the entry, for keeping track of its runs.
+func (e Entry) Key() string {
+	if len(e.Repos) == 0 {
+		return e.Owner
+	}
+	return e.Owner + "/" + strings.Join(e.Repos, ",")
+}
+
+// ParseConfig parses and validates a JSON configuration.
+func ParseConfig(bs []byte) (Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(bs, &cfg); err != nil {
+		return Config{}, &ConfigError{err}
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, &ConfigError{err}
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	for _, kind := range c.AllowedActions {
+		if !validActionKind(kind) {
+			return fmt.Errorf("unknown allowed action %q", kind)
+		}
+	}
+	for i := range c.Entries {
+		for j := range c.Entries[i].Directives {
+			c.Entries[i].Directives[j].capKey = fmt.Sprintf("%d/%d", i, j)
+		}
+		c.Entries[i].setDefaults()
+		if err := c.Entries[i].validate(); err != nil {
+			return err
+		}
+	}
+	names := make(map[string]bool)
+	for i := range c.Campaigns {
+		if err := c.Campaigns[i].validate(); err != nil {
+			return err
+		}
+		if names[c.Campaigns[i].Name] {
+			return fmt.Errorf("duplicate campaign %q", c.Campaigns[i].Name)
+		}
+		names[c.Campaigns[i].Name] = true
+	}
+	if c.ChangelogIssue != "" {
+		t, err := parseIssueRef(c.ChangelogIssue)
+		if err != nil {
+			return fmt.Errorf("changelogIssue: %w", err)
+		}
+		c.changelogIssue = t
+	}
+	return nil
+}
+
+// allows returns true if actions of the given kind may be performed.
+func (c Config) allows(kind string) bool {
+	if len(c.AllowedActions) == 0 {
+		return true
+	}
+	for _, k := range c.AllowedActions {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Entry) setDefaults() {
+	for i := range e.Directives {
+		d := &e.Directives[i]
+		if d.Name == "" {
+			d.Name = strconv.Itoa(i)
+		}
+		if d.TransferTo != "" && !strings.Contains(d.TransferTo, "/") {
+			d.TransferTo = e.Owner + "/" + d.TransferTo
+		}
+		if d.Close && d.CloseComment == "" && len(d.CloseCommentByAssociation) == 0 {
+			d.CloseComment = e.CloseComment
+		}
+		if (d.Close || d.Lock) && d.Label != "" && d.DaysAfterWarn > 0 && d.WarnComment == "" {
+			d.WarnComment = e.WarnComment
+		}
+	}
+}
+
+func (e *Entry) validate() error {
+	for i := range e.Discussions {
+		if e.Discussions[i].Name == "" {
+			e.Discussions[i].Name = "discussion" + strconv.Itoa(i)
+		}
+		if err := e.Discussions[i].validate(); err != nil {
+			return fmt.Errorf("%s discussion directive %d: %w", e.Owner, i, err)
+		}
+	}
+	if e.Owner == "" {
+		return errors.New("every config entry must set `owner`")
+	}
+	if err := validHousekeeping(e.Housekeeping); err != nil {
+		return err
+	}
+	if e.OrgSearch && len(e.Repos) > 0 {
+		return fmt.Errorf("%s: orgSearch is for entries without repos", e.Owner)
+	}
+	if e.Schedule != "" {
+		if _, err := cron.ParseStandard(e.Schedule); err != nil {
+			return fmt.Errorf("%s schedule: %w", e.Owner, err)
+		}
+	}
+	names := make(map[string]bool)
+	for i := range e.Labels {
+		if err := e.Labels[i].validate(); err != nil {
+			return fmt.Errorf("%s: %w", e.Owner, err)
+		}
+		name := strings.ToLower(e.Labels[i].Name)
+		if names[name] {
+			return fmt.Errorf("%s: duplicate label %q", e.Owner, e.Labels[i].Name)
+		}
+		names[name] = true
+	}
+	for i := range e.Directives {
+		if err := e.Directives[i].validate(); err != nil {
+			return fmt.Errorf("%s directive %d: %w", e.Owner, i, err)
+		}
+		if err := e.Directives[i].checkQueryScope(e.Owner); err != nil {
+			return fmt.Errorf("%s directive %d: %w", e.Owner, i, err)
+		}
+		if to := e.Directives[i].TransferTo; to != "" {
+			if err := checkTransferOwner(e.Owner, to); err != nil {
+				return fmt.Errorf("%s directive %d: %w", e.Owner, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Directive) validate() error {
+	if err := d.lintQuery(); err != nil {
+		return err
+	}
+	switch d.Threshold {
+	case "", thresholdAtLeast, thresholdMoreThan:
+	default:
+		return fmt.Errorf("unknown threshold %q", d.Threshold)
+	}
+	switch d.DayCounting {
+	case "", dayCountingElapsed, dayCountingCalendar, dayCountingBusiness:
+	default:
+		return fmt.Errorf("unknown day counting %q", d.DayCounting)
+	}
+	for n := range d.FreezeWindows {
+		if err := d.FreezeWindows[n].validate(); err != nil {
+			return fmt.Errorf("freezeWindows %d: %w", n, err)
+		}
+	}
+	switch d.Activity {
+	case "", activityAny, activityHuman:
+	default:
+		return fmt.Errorf("unknown activity %q", d.Activity)
+	}
+	if d.NeedsInfo != "" && d.DaysNotUpdated <= 0 {
+		return errors.New("needsInfo requires daysNotUpdated")
+	}
+	switch d.TrackedTasks {
+	case "", trackedTasksSkip:
+	case trackedTasksFlag:
+		if d.TrackedTaskLabel == "" {
+			return errors.New("trackedTasks \"flag\" requires trackedTaskLabel")
+		}
+	default:
+		return fmt.Errorf("unknown trackedTasks %q", d.TrackedTasks)
+	}
+	if d.SummaryIssue != "" {
+		t, err := parseIssueRef(d.SummaryIssue)
+		if err != nil {
+			return fmt.Errorf("summaryIssue: %w", err)
+		}
+		d.summaryIssue = t
+	}
+	if d.EOL != nil {
+		if err := d.EOL.validate(); err != nil {
+			return fmt.Errorf("eol: %w", err)
+		}
+	}
+	if d.SlashCommands != nil {
+		if err := d.SlashCommands.validate(); err != nil {
+			return err
+		}
+	}
+	if d.UnlockAfterDays > 0 && d.Resurrect != nil {
+		return errors.New("unlockAfterDays and resurrect can't be combined")
+	}
+	if d.Resurrect != nil && d.Resurrect.Reactions <= 0 && d.Resurrect.Comments <= 0 {
+		return errors.New("resurrect requires reactions or comments")
+	}
+	for _, kind := range d.NoCloseFor {
+		switch kind {
+		case authorContributors, authorSponsors:
+		default:
+			return fmt.Errorf("unknown noCloseFor %q", kind)
+		}
+	}
+	for _, team := range d.ExemptIfCommentedByTeam {
+		if strings.Count(team, "/") != 1 {
+			return fmt.Errorf("exemptIfCommentedByTeam %q is not \"org/team\"", team)
+		}
+	}
+	if d.TransferTo != "" {
+		if err := checkTransferTo(d.TransferTo); err != nil {
+			return err
+		}
+		if d.Close || d.Lock {
+			return errors.New("transferTo can't be combined with close or lock")
+		}
+	}
+	if d.TransferComment != "" {
+		if d.TransferTo == "" {
+			return errors.New("transferComment requires transferTo")
+		}
+		tmpl, err := parseCommentTemplate("transferComment", d.TransferComment)
+		if err != nil {
+			return fmt.Errorf("transferComment: %w", err)
+		}
+		d.transferComment = tmpl
+	}
+	for _, p := range d.Patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return fmt.Errorf("patterns: %w", err)
+		}
+		d.patterns = append(d.patterns, re)
+	}
+	switch d.ItemType {
+	case "", itemBoth, itemIssue, itemPR:
+	default:
+		return fmt.Errorf("unknown itemType %q", d.ItemType)
+	}
+	if d.Draft && d.ItemType != itemPR {
+		return errors.New("draft requires itemType \"pr\"")
+	}
+	for n, al := range d.AgeLabels {
+		if al.Label == "" {
+			return errors.New("every age label must set `label`")
+		}
+		if n > 0 && al.Days <= d.AgeLabels[n-1].Days {
+			return errors.New("ageLabels must be in order of increasing days")
+		}
+	}
+	if d.Pin && d.Unpin {
+		return errors.New("pin can't be combined with unpin")
+	}
+	if (d.Pin || d.Unpin) && d.ItemType == itemPR {
+		return errors.New("pull requests can't be pinned")
+	}
+	if d.Unlock && d.Lock {
+		return errors.New("unlock can't be combined with lock")
+	}
+	if d.DaysLocked > 0 && !d.Unlock {
+		return errors.New("daysLocked requires unlock")
+	}
+	if d.LockSummary != nil {
+		if err := d.LockSummary.validate(); err != nil {
+			return fmt.Errorf("lockSummary: %w", err)
+		}
+	}
+	if err := checkLockReason(d.LockReason); err != nil {
+		return err
+	}
+	if d.ConvertToDiscussion != "" {
+		if d.TransferTo != "" {
+			return errors.New("convertToDiscussion can't be combined with transferTo")
+		}
+		if d.ItemType == itemPR {
+			return errors.New("pull requests can't be converted to discussions")
+		}
+		if d.Unlock {
+			return errors.New("convertToDiscussion can't be combined with unlock")
+		}
+		d.ItemType = itemIssue
+		d.Close, d.Lock = true, true
+	}
+	if d.CloseComment != "" {
+		tmpl, err := parseCommentTemplate("closeComment", d.CloseComment)
+		if err != nil {
+			return fmt.Errorf("closeComment: %w", err)
+		}
+		d.closeComment = tmpl
+	}
+	if d.WarnComment != "" {
+		if d.Label == "" {
+			return errors.New("warnComment requires a label")
+		}
+		tmpl, err := parseCommentTemplate("warnComment", d.WarnComment)
+		if err != nil {
+			return fmt.Errorf("warnComment: %w", err)
+		}
+		d.warnComment = tmpl
+	}
+	if d.GraceDays > 0 {
+		if d.WarnComment == "" {
+			return errors.New("graceDays requires a warnComment")
+		}
+		if d.ItemType == itemIssue {
+			return errors.New("graceDays only applies to pull requests")
+		}
+	}
+	for assoc, text := range d.CloseCommentByAssociation {
+		switch assoc {
+		case "COLLABORATOR", "CONTRIBUTOR", "FIRST_TIMER", "FIRST_TIME_CONTRIBUTOR", "MANNEQUIN", "MEMBER", "NONE", "OWNER":
+		default:
+			return fmt.Errorf("closeCommentByAssociation: unknown association %q", assoc)
+		}
+		tmpl, err := parseCommentTemplate("closeComment."+assoc, text)
+		if err != nil {
+			return fmt.Errorf("closeCommentByAssociation %s: %w", assoc, err)
+		}
+		if d.closeCommentByAssociation == nil {
+			d.closeCommentByAssociation = make(map[string]*template.Template)
+		}
+		d.closeCommentByAssociation[assoc] = tmpl
+	}
+	switch d.CommentReaction {
+	case "", "+1", "-1", "laugh", "confused", "heart", "hooray", "rocket", "eyes":
+	default:
+		return fmt.Errorf("unknown comment reaction %q", d.CommentReaction)
+	}
+	if d.When != "" {
+		prog, err := compileWhen(d.When)
+		if err != nil {
+			return fmt.Errorf("when: %w", err)
+		}
+		d.when = prog
+		d.whenEvents = usesEvents(prog)
+	}
+	if d.Script != "" {
+		fn, err := loadScript(d.Script)
+		if err != nil {
+			return fmt.Errorf("script: %w", err)
+		}
+		d.script = fn
+	}
+	if d.Plugin != "" {
+		if d.Script != "" {
+			return errors.New("only one of script and plugin may be set")
+		}
+		p, err := loadPlugin(context.Background(), d.Plugin)
+		if err != nil {
+			return fmt.Errorf("plugin: %w", err)
+		}
+		d.plugin = p
+	}
+	return nil
+}
+
+func checkTransferTo(to string) error {
+	if strings.Count(to, "/") != 1 {
+		return fmt.Errorf("transferTo %q is not of the form owner/repo", to)
+	}
+	return nil
+}
+
+// checkTransferOwner rejects transfers to repos of other owners, which
+// GitHub doesn't allow.
+func checkTransferOwner(owner, to string) error {
+	if !strings.EqualFold(strings.Split(to, "/")[0], owner) {
+		return fmt.Errorf("can't transfer issues to %s, of another owner", to)
+	}
+	return nil
+}
+
+func checkLockReason(reason string) error {
+	switch reason {
+	case "", "resolved", "off-topic", "too heated", "spam":
+		return nil
+	default:
+		return fmt.Errorf("unknown lockReason %q", reason)
+	}
+}
+
+// reached returns true when the time since t has reached the given number of
+// days, as counted per the directive. A zero threshold is always reached.
+func (d Directive) reached(now, t time.Time, days int) bool {
+	if days <= 0 {
+		return true
+	}
+	since := d.daysSince(now, t)
+	if d.Threshold == thresholdMoreThan {
+		return since > days
+	}
+	return since >= days
+}
+
+// daysSince returns the number of days since t, as counted per the
+// directive.
+func (d Directive) daysSince(now, t time.Time) int {
+	switch d.DayCounting {
+	case dayCountingCalendar:
+		if len(d.FreezeWindows) == 0 {
+			return calendarDaysSince(now, t)
+		}
+		return countDays(now, t, func(day time.Time) bool {
+			return !frozen(d.FreezeWindows, day)
+		})
+	case dayCountingBusiness:
+		return countDays(now, t, func(day time.Time) bool {
+			return businessDay(day) && !frozen(d.FreezeWindows, day)
+		})
+	}
+	if len(d.FreezeWindows) > 0 && now.After(t) {
+		return int((now.Sub(t) - frozenDuration(d.FreezeWindows, t, now)) / 24 / time.Hour)
+	}
+	return elapsedDaysSince(now, t)
+}
+
+func elapsedDaysSince(now, t time.Time) int {
+	return int(now.Sub(t) / 24 / time.Hour)
+}
+
+// calendarDaysSince returns the number of date changes between t and now,
+// in now's time zone, disregarding the time of day.
+func calendarDaysSince(now, t time.Time) int {
+	y, m, d := t.In(now.Location()).Date()
+	then := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	y, m, d = now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	return int(today.Sub(then) / 24 / time.Hour)
+}
+
+// comment returns the text for a comment of ours, with the feedback link
+// added when configured.
+func (d Directive) comment(text string) string {
+	if d.FeedbackURL == "" {
+		return text
+	}
+	return fmt.Sprintf("%s\n\n<sub>Feedback on this message? %s</sub>", text, d.FeedbackURL)
+}
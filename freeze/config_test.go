@@ -0,0 +1,166 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // for the DST cases, wherever the tests run
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestDaysSince(t *testing.T) {
+	sthlm := mustLoadLocation(t, "Europe/Stockholm")
+	nyc := mustLoadLocation(t, "America/New_York")
+	at := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	cases := []struct {
+		name                        string
+		then, now                   time.Time
+		elapsed, calendar, business int
+	}{
+		{
+			// The day of the spring DST change is 23 hours long.
+			name: "spring forward",
+			then: at(sthlm, 2024, 3, 30, 12, 0), now: at(sthlm, 2024, 3, 31, 12, 0),
+			elapsed: 0, calendar: 1, business: 0,
+		},
+		{
+			// And the day of the autumn change 25 hours.
+			name: "fall back",
+			then: at(sthlm, 2024, 10, 26, 12, 0), now: at(sthlm, 2024, 10, 27, 11, 30),
+			elapsed: 1, calendar: 1, business: 0,
+		},
+		{
+			name: "fall back past midnight",
+			then: at(sthlm, 2024, 10, 26, 23, 30), now: at(sthlm, 2024, 10, 27, 0, 10),
+			elapsed: 0, calendar: 1, business: 0,
+		},
+		{
+			name: "spring forward week",
+			then: at(nyc, 2024, 3, 8, 9, 0), now: at(nyc, 2024, 3, 15, 9, 0),
+			elapsed: 6, calendar: 7, business: 5,
+		},
+		{
+			// Calendar days are counted in now's time zone.
+			name: "other time zone",
+			then: at(time.UTC, 2024, 6, 1, 23, 0), now: at(sthlm, 2024, 6, 2, 9, 0),
+			elapsed: 0, calendar: 0, business: 0,
+		},
+		{
+			name: "over Feb 29",
+			then: at(time.UTC, 2024, 2, 28, 12, 0), now: at(time.UTC, 2024, 3, 1, 12, 0),
+			elapsed: 2, calendar: 2, business: 2,
+		},
+		{
+			name: "no Feb 29",
+			then: at(time.UTC, 2023, 2, 28, 12, 0), now: at(time.UTC, 2023, 3, 1, 12, 0),
+			elapsed: 1, calendar: 1, business: 1,
+		},
+		{
+			name: "on Feb 29",
+			then: at(time.UTC, 2024, 2, 29, 0, 0), now: at(time.UTC, 2025, 2, 28, 0, 0),
+			elapsed: 365, calendar: 365, business: 261,
+		},
+		{
+			name: "weekend",
+			then: at(time.UTC, 2024, 3, 1, 17, 0), now: at(time.UTC, 2024, 3, 4, 9, 0),
+			elapsed: 2, calendar: 3, business: 1,
+		},
+		{
+			name: "future",
+			then: at(time.UTC, 2024, 3, 2, 0, 0), now: at(time.UTC, 2024, 3, 1, 0, 0),
+			elapsed: -1, calendar: -1, business: 0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := FixedClock(tc.now)
+			for _, c := range []struct {
+				counting string
+				want     int
+			}{
+				{dayCountingElapsed, tc.elapsed},
+				{dayCountingCalendar, tc.calendar},
+				{dayCountingBusiness, tc.business},
+			} {
+				d := Directive{DayCounting: c.counting}
+				if got := d.daysSince(clock.Now(), tc.then); got != c.want {
+					t.Errorf("%s: got %d days, want %d", c.counting, got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCalendarDaysSinceMatchesCountDays(t *testing.T) {
+	sthlm := mustLoadLocation(t, "Europe/Stockholm")
+	then := time.Date(2023, 12, 31, 22, 0, 0, 0, sthlm)
+	for now := then; now.Year() < 2025; now = now.Add(7 * time.Hour) {
+		want := countDays(now, then, func(time.Time) bool { return true })
+		if got := calendarDaysSince(now, then); got != want {
+			t.Fatalf("%v: got %d days, want %d", now, got, want)
+		}
+	}
+}
+
+func TestReached(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := FixedClock(now)
+	cases := []struct {
+		threshold string
+		since     time.Duration
+		days      int
+		want      bool
+	}{
+		{thresholdAtLeast, 0, 0, true},
+		{thresholdAtLeast, 24 * time.Hour, 1, true},
+		{thresholdAtLeast, 24*time.Hour - time.Second, 1, false},
+		{thresholdMoreThan, 24 * time.Hour, 1, false},
+		{thresholdMoreThan, 48 * time.Hour, 1, true},
+		{"", 30 * 24 * time.Hour, 30, true},
+		{"", 29 * 24 * time.Hour, 30, false},
+		{thresholdMoreThan, 0, 0, true},
+	}
+	for _, tc := range cases {
+		d := Directive{Threshold: tc.threshold}
+		if got := d.reached(clock.Now(), now.Add(-tc.since), tc.days); got != tc.want {
+			t.Errorf("%q %v since, %d days: got %v, want %v", tc.threshold, tc.since, tc.days, got, tc.want)
+		}
+	}
+}
+
+func TestDaysSinceFreezeWindows(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	then := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	windows := []FreezeWindow{{
+		start: time.Date(2023, 12, 24, 0, 0, 0, 0, time.UTC),
+		end:   time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+	}}
+	cases := []struct {
+		counting string
+		want     int
+	}{
+		// 1.5 of the 9 days are frozen.
+		{dayCountingElapsed, 7},
+		// Jan 2 is the only frozen date after then.
+		{dayCountingCalendar, 8},
+		// Of Jan 2-10, Jan 6 and 7 are a weekend.
+		{dayCountingBusiness, 6},
+	}
+	for _, tc := range cases {
+		d := Directive{DayCounting: tc.counting, FreezeWindows: windows}
+		if got := d.daysSince(now, then); got != tc.want {
+			t.Errorf("%s: got %d days, want %d", tc.counting, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package freeze
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMagic starts encrypted state files. Those starting with
+// legacyEncryptedMagic are from before keys were derived with scrypt, and
+// are still read.
+var (
+	encryptedMagic       = []byte("freezebot-aes-gcm-2\n")
+	legacyEncryptedMagic = []byte("freezebot-aes-gcm-1\n")
+)
+
+// auditLinePrefix starts encrypted audit log lines with scrypt derived
+// keys. It's not valid base64, unlike the lines from before.
+const auditLinePrefix = "2:"
+
+// saltSize is the length of the scrypt salt stored with sealed data.
+const saltSize = 16
+
+// The scrypt parameters, as recommended for interactive logins in 2017.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+var (
+	// processSalt is the salt for everything sealed by this process,
+	// so that the key is derived only once per secret.
+	processSalt     []byte
+	processSaltErr  error
+	processSaltOnce sync.Once
+
+	// derivedKeys caches keys by the SHA-256 of secret and salt.
+	derivedKeys sync.Map
+)
+
+// deriveKey returns the AES-256 key for the secret and salt, so that any
+// passphrase or key file content can be used and passphrases are costly to
+// guess.
+func deriveKey(secret, salt []byte) ([]byte, error) {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(secret)
+	var id [sha256.Size]byte
+	copy(id[:], h.Sum(nil))
+	if key, ok := derivedKeys.Load(id); ok {
+		return key.([]byte), nil
+	}
+	key, err := scrypt.Key(secret, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys.Store(id, key)
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext with AES-256-GCM under a key derived from
+// the secret, returning the salt, the nonce and the ciphertext.
+func seal(secret, plaintext []byte) ([]byte, error) {
+	processSaltOnce.Do(func() {
+		processSalt = make([]byte, saltSize)
+		_, processSaltErr = rand.Read(processSalt)
+	})
+	if processSaltErr != nil {
+		return nil, processSaltErr
+	}
+	key, err := deriveKey(secret, processSalt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(append([]byte{}, processSalt...), nonce...)
+	return aead.Seal(out, nonce, plaintext, nil), nil
+}
+
+func unseal(secret, data []byte) ([]byte, error) {
+	if len(data) < saltSize {
+		return nil, errors.New("encrypted data too short")
+	}
+	key, err := deriveKey(secret, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	return open(key, data[saltSize:])
+}
+
+// unsealLegacy decrypts data sealed with the SHA-256 of the secret as the
+// key, as done before scrypt.
+func unsealLegacy(secret, data []byte) ([]byte, error) {
+	key := sha256.Sum256(secret)
+	return open(key[:], data)
+}
+
+func open(key, data []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted data too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+// isSealed returns true if the stored state is encrypted.
+func isSealed(bs []byte) bool {
+	return bytes.HasPrefix(bs, encryptedMagic) || bytes.HasPrefix(bs, legacyEncryptedMagic)
+}
+
+// sealState encrypts state for storing.
+func sealState(secret, bs []byte) ([]byte, error) {
+	sealed, err := seal(secret, bs)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedMagic...), sealed...), nil
+}
+
+// unsealState reverses sealState, for state sealed now or before scrypt.
+func unsealState(secret, bs []byte) ([]byte, error) {
+	if bytes.HasPrefix(bs, legacyEncryptedMagic) {
+		return unsealLegacy(secret, bs[len(legacyEncryptedMagic):])
+	}
+	return unseal(secret, bytes.TrimPrefix(bs, encryptedMagic))
+}
+
+// EncryptedLineWriter encrypts each line written to it separately, writing
+// it base64 encoded on a line of its own, so that encrypted audit logs can
+// still be appended to. Each Write must be one or more whole lines, as
+// written by a JSONSink.
+type EncryptedLineWriter struct {
+	W      io.Writer
+	Secret []byte
+}
+
+func (w *EncryptedLineWriter) Write(bs []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(bs, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		sealed, err := seal(w.Secret, line)
+		if err != nil {
+			return 0, err
+		}
+		out := make([]byte, len(auditLinePrefix)+base64.StdEncoding.EncodedLen(len(sealed))+1)
+		copy(out, auditLinePrefix)
+		base64.StdEncoding.Encode(out[len(auditLinePrefix):], sealed)
+		out[len(out)-1] = '\n'
+		if _, err := w.W.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(bs), nil
+}
+
+// DecryptLine reverses EncryptedLineWriter for one line, also for lines
+// written before keys were derived with scrypt.
+func DecryptLine(secret []byte, line string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(line, auditLinePrefix); ok {
+		sealed, err := base64.StdEncoding.DecodeString(rest)
+		if err != nil {
+			return nil, err
+		}
+		return unseal(secret, sealed)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return nil, err
+	}
+	return unsealLegacy(secret, sealed)
+}
@@ -0,0 +1,112 @@
+package freeze
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// legacySeal seals as done before keys were derived with scrypt.
+func legacySeal(t *testing.T, secret, plaintext []byte) []byte {
+	t.Helper()
+	key := sha256.Sum256(secret)
+	aead, err := newGCM(key[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+func TestSealUnseal(t *testing.T) {
+	secret := []byte("correct horse")
+	plain := []byte("hello world")
+	sealed, err := seal(secret, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plain) {
+		t.Fatal("sealed data contains the plaintext")
+	}
+	got, err := unseal(secret, sealed)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("unseal = %q, %v", got, err)
+	}
+
+	if _, err := unseal([]byte("wrong"), sealed); err == nil {
+		t.Error("unsealed with the wrong secret")
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := unseal(secret, sealed); err == nil {
+		t.Error("unsealed modified data")
+	}
+	if _, err := unseal(secret, []byte("short")); err == nil {
+		t.Error("unsealed too short data")
+	}
+}
+
+func TestSealState(t *testing.T) {
+	secret := []byte("correct horse")
+	plain := []byte(`{"calmh/freezebot":{}}`)
+	if isSealed(plain) {
+		t.Fatal("plain state reported as sealed")
+	}
+
+	sealed, err := sealState(secret, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isSealed(sealed) {
+		t.Fatal("sealed state not reported as sealed")
+	}
+	got, err := unsealState(secret, sealed)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("unsealState = %q, %v", got, err)
+	}
+
+	legacy := append(append([]byte{}, legacyEncryptedMagic...), legacySeal(t, secret, plain)...)
+	if !isSealed(legacy) {
+		t.Fatal("legacy state not reported as sealed")
+	}
+	got, err = unsealState(secret, legacy)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("unsealState of legacy state = %q, %v", got, err)
+	}
+}
+
+func TestEncryptedLines(t *testing.T) {
+	secret := []byte("correct horse")
+	var buf bytes.Buffer
+	w := &EncryptedLineWriter{W: &buf, Secret: secret}
+	in := "{\"a\":1}\n{\"b\":2}\n"
+	if n, err := w.Write([]byte(in)); err != nil || n != len(in) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	// Lines from before scrypt are still read.
+	lines = append(lines, base64.StdEncoding.EncodeToString(legacySeal(t, secret, []byte("{\"c\":3}\n"))))
+	want := []string{"{\"a\":1}\n", "{\"b\":2}\n", "{\"c\":3}\n"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for n, line := range lines {
+		got, err := DecryptLine(secret, line)
+		if err != nil || string(got) != want[n] {
+			t.Errorf("line %d: got %q, %v; want %q", n, got, err, want[n])
+		}
+	}
+
+	if _, err := DecryptLine([]byte("wrong"), lines[0]); err == nil {
+		t.Error("decrypted with the wrong secret")
+	}
+	if _, err := DecryptLine(secret, auditLinePrefix+"!!"); err == nil {
+		t.Error("decrypted invalid base64")
+	}
+}
@@ -0,0 +1,89 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// discussionBody is the body of a discussion made from an issue, quoting
+// the original.
+func discussionBody(author, url, body string) string {
+	return fmt.Sprintf("_Originally posted by @%s in %s_\n\n%s", author, url, body)
+}
+
+// convertToDiscussion creates a discussion in the category of the issue's
+// repo from the action's title and body, and comments on the issue with a
+// link to it. The discussion URL is recorded in the action.
+func (s *GitHubSink) convertToDiscussion(ctx context.Context, a *Action) error {
+	fullName := a.Owner + "/" + a.Repo
+	repoID, err := s.repoID(ctx, fullName)
+	if err != nil {
+		return fmt.Errorf("looking up %s: %w", fullName, err)
+	}
+	categoryID, err := s.discussionCategoryID(ctx, a.Owner, a.Repo, a.Category)
+	if err != nil {
+		return fmt.Errorf("looking up discussion category %q: %w", a.Category, err)
+	}
+
+	err = retry(ctx, "Converting", a.Issue, func() error {
+		var res struct {
+			CreateDiscussion struct {
+				Discussion struct {
+					URL string
+				}
+			}
+		}
+		err := graphQL(ctx, s.Client, `mutation($repo: ID!, $category: ID!, $title: String!, $body: String!) {
+			createDiscussion(input: {repositoryId: $repo, categoryId: $category, title: $title, body: $body}) { discussion { url } }
+		}`, map[string]interface{}{"repo": repoID, "category": categoryID, "title": a.Title, "body": a.Body}, &res)
+		a.DiscussionURL = res.CreateDiscussion.Discussion.URL
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	comment := fmt.Sprintf("This issue has been moved to a discussion: %s", a.DiscussionURL)
+	a.CommentID, err = commentIssue(ctx, s.Client, a.Owner, a.Repo, a.Issue, comment)
+	return err
+}
+
+func (s *GitHubSink) discussionCategoryID(ctx context.Context, owner, repo, category string) (string, error) {
+	key := owner + "/" + repo + ":" + category
+	s.mut.Lock()
+	id, ok := s.categoryIDs[key]
+	s.mut.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var res struct {
+		Repository struct {
+			DiscussionCategories struct {
+				Nodes []struct {
+					ID   string
+					Name string
+				}
+			}
+		}
+	}
+	err := graphQL(ctx, s.Client, `query($owner: String!, $repo: String!) {
+		repository(owner: $owner, name: $repo) { discussionCategories(first: 100) { nodes { id name } } }
+	}`, map[string]interface{}{"owner": owner, "repo": repo}, &res)
+	if err != nil {
+		return "", classifyAPIError(err)
+	}
+	for _, c := range res.Repository.DiscussionCategories.Nodes {
+		if strings.EqualFold(c.Name, category) {
+			s.mut.Lock()
+			if s.categoryIDs == nil {
+				s.categoryIDs = make(map[string]string)
+			}
+			s.categoryIDs[key] = c.ID
+			s.mut.Unlock()
+			return c.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no such category in %s/%s", owner, repo)
+}
@@ -0,0 +1,231 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A DiscussionDirective selects discussions and says what to do with them.
+// Discussions are only reachable with GraphQL.
+type DiscussionDirective struct {
+	// Name identifies the directive in logs and metrics. It defaults to
+	// "discussion" and the index of the directive within the entry.
+	Name string
+	// Category, if set, limits the directive to discussions in the named
+	// category.
+	Category string
+	// DaysNotUpdated is required, so that we don't act on the same
+	// discussions every run.
+	DaysNotUpdated int
+	// Unanswered limits the directive to discussions in answerable
+	// categories that don't have an answer.
+	Unanswered bool
+	// MarkAnswer marks the top level comment with the most upvotes, if
+	// any, as the answer, in answerable categories.
+	MarkAnswer bool
+	// Comment, if set, is Markdown to comment with.
+	Comment string
+	Lock    bool
+}
+
+func (d *DiscussionDirective) validate() error {
+	if d.DaysNotUpdated <= 0 {
+		return errors.New("daysNotUpdated is required")
+	}
+	if !d.MarkAnswer && d.Comment == "" && !d.Lock {
+		return errors.New("no action; set markAnswer, comment or lock")
+	}
+	return nil
+}
+
+// discussion is a discussion as returned by the GraphQL API.
+type discussion struct {
+	ID        string
+	Number    int
+	UpdatedAt time.Time
+	Locked    bool
+	Closed    bool
+	Answer    *struct{ ID string }
+	Category  struct {
+		Name         string
+		IsAnswerable bool
+	}
+	Comments struct {
+		Nodes []struct {
+			ID          string
+			UpvoteCount int
+		}
+	}
+}
+
+// handleRepoDiscussions applies the discussion directives to the repo.
+func (r *Runner) handleRepoDiscussions(ctx context.Context, owner, repo string, directives []DiscussionDirective, sink ActionSink) error {
+	for _, d := range directives {
+		cutoff := r.Clock.Now().Add(-time.Duration(d.DaysNotUpdated) * 24 * time.Hour)
+		err := r.staleDiscussions(ctx, owner, repo, cutoff, d.MarkAnswer, func(disc discussion) error {
+			if disc.Locked || disc.Closed {
+				return nil
+			}
+			if d.Category != "" && !strings.EqualFold(disc.Category.Name, d.Category) {
+				return nil
+			}
+			if d.Unanswered && (!disc.Category.IsAnswerable || disc.Answer != nil) {
+				return nil
+			}
+			for _, a := range d.actions(owner, repo, disc) {
+				if err := sink.Act(ctx, a); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("discussion directive %s: %w", d.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d DiscussionDirective) actions(owner, repo string, disc discussion) []Action {
+	var actions []Action
+	add := func(kind string, mod func(*Action)) {
+		a := Action{Owner: owner, Repo: repo, Issue: disc.Number, Directive: d.Name, Kind: kind, IssueUpdatedAt: disc.UpdatedAt, IssueNodeID: disc.ID}
+		if mod != nil {
+			mod(&a)
+		}
+		actions = append(actions, a)
+	}
+
+	if d.MarkAnswer && disc.Category.IsAnswerable && disc.Answer == nil {
+		best, votes := "", 0
+		for _, c := range disc.Comments.Nodes {
+			if c.UpvoteCount > votes {
+				best, votes = c.ID, c.UpvoteCount
+			}
+		}
+		if best != "" {
+			add(ActionAnswer, func(a *Action) { a.CommentNodeID = best })
+		}
+	}
+	if d.Comment != "" {
+		add(ActionDiscussionComment, func(a *Action) { a.Comment = d.Comment })
+	}
+	if d.Lock {
+		add(ActionDiscussionLock, nil)
+	}
+	return actions
+}
+
+// staleDiscussions calls fn for each discussion in the repo not updated
+// since the cutoff, least recently updated first, with its top level
+// comments if wanted.
+func (r *Runner) staleDiscussions(ctx context.Context, owner, repo string, cutoff time.Time, comments bool, fn func(discussion) error) error {
+	var cursor *string
+	for {
+		var res struct {
+			Repository struct {
+				Discussions struct {
+					Nodes    []discussion
+					PageInfo struct {
+						HasNextPage bool
+						EndCursor   string
+					}
+				}
+			}
+		}
+		err := graphQL(ctx, r.Client, `query($owner: String!, $repo: String!, $cursor: String, $comments: Boolean!) {
+			repository(owner: $owner, name: $repo) {
+				discussions(first: 50, after: $cursor, orderBy: {field: UPDATED_AT, direction: ASC}) {
+					nodes {
+						id number updatedAt locked closed
+						answer { id }
+						category { name isAnswerable }
+						comments(first: 100) @include(if: $comments) { nodes { id upvoteCount } }
+					}
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}`, map[string]interface{}{"owner": owner, "repo": repo, "cursor": cursor, "comments": comments}, &res)
+		if err != nil {
+			return classifyAPIError(err)
+		}
+		ds := res.Repository.Discussions
+		for _, disc := range ds.Nodes {
+			if disc.UpdatedAt.After(cutoff) {
+				return nil
+			}
+			if err := fn(disc); err != nil {
+				return err
+			}
+		}
+		if !ds.PageInfo.HasNextPage {
+			return nil
+		}
+		cursor = &ds.PageInfo.EndCursor
+	}
+}
+
+// onDiscussion returns true if the action kind acts on a discussion rather
+// than an issue.
+func onDiscussion(kind string) bool {
+	switch kind {
+	case ActionAnswer, ActionDiscussionComment, ActionDiscussionLock:
+		return true
+	default:
+		return false
+	}
+}
+
+// discussionUpdatedAt returns when the discussion was last updated.
+func (r *Runner) discussionUpdatedAt(ctx context.Context, owner, repo string, number int) (time.Time, error) {
+	var res struct {
+		Repository struct {
+			Discussion struct {
+				UpdatedAt time.Time
+			}
+		}
+	}
+	err := graphQL(ctx, r.Client, `query($owner: String!, $repo: String!, $number: Int!) {
+		repository(owner: $owner, name: $repo) { discussion(number: $number) { updatedAt } }
+	}`, map[string]interface{}{"owner": owner, "repo": repo, "number": number}, &res)
+	return res.Repository.Discussion.UpdatedAt, err
+}
+
+func (s *GitHubSink) actOnDiscussion(ctx context.Context, a *Action) error {
+	switch a.Kind {
+	case ActionAnswer:
+		infof("Marking answer on discussion %d", a.Issue)
+		return retry(ctx, "Marking answer on", a.Issue, func() error {
+			return graphQL(ctx, s.Client, `mutation($id: ID!) {
+				markDiscussionCommentAsAnswer(input: {id: $id}) { discussion { number } }
+			}`, map[string]interface{}{"id": a.CommentNodeID}, nil)
+		})
+	case ActionDiscussionComment:
+		infof("Commenting on discussion %d", a.Issue)
+		return retry(ctx, "Commenting on", a.Issue, func() error {
+			var res struct {
+				AddDiscussionComment struct {
+					Comment struct {
+						DatabaseID int64
+					}
+				}
+			}
+			err := graphQL(ctx, s.Client, `mutation($id: ID!, $body: String!) {
+				addDiscussionComment(input: {discussionId: $id, body: $body}) { comment { databaseId } }
+			}`, map[string]interface{}{"id": a.IssueNodeID, "body": a.Comment}, &res)
+			a.CommentID = res.AddDiscussionComment.Comment.DatabaseID
+			return err
+		})
+	case ActionDiscussionLock:
+		infof("Locking discussion %d", a.Issue)
+		return retry(ctx, "Locking", a.Issue, func() error {
+			return graphQL(ctx, s.Client, `mutation($id: ID!) {
+				lockLockable(input: {lockableId: $id}) { lockedRecord { locked } }
+			}`, map[string]interface{}{"id": a.IssueNodeID}, nil)
+		})
+	}
+	return fmt.Errorf("unknown action %q", a.Kind)
+}
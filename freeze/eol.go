@@ -0,0 +1,151 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/github"
+)
+
+// defaultVersionPattern matches things like "v1.2.3" and "1.27".
+const defaultVersionPattern = `\bv?(\d+\.\d+(?:\.\d+)?)\b`
+
+// EOL configures acting on issues that report a version older than the
+// oldest supported one.
+type EOL struct {
+	// Pattern is a regexp finding the version in the issue body. The first
+	// capture group, or else the whole match, is the version. The first
+	// match in the body is used.
+	Pattern string
+	// MinVersion is the oldest supported version, e.g. "1.27".
+	MinVersion string
+	Label      string
+	Close      bool
+	// Comment is a template for the comment, with {{.Version}} and
+	// {{.MinVersion}} in addition to the repo metadata.
+	Comment string
+
+	pattern *regexp.Regexp
+	comment *template.Template
+}
+
+func (e *EOL) validate() error {
+	if e.Pattern == "" {
+		e.Pattern = defaultVersionPattern
+	}
+	var err error
+	if e.pattern, err = regexp.Compile(e.Pattern); err != nil {
+		return fmt.Errorf("pattern: %w", err)
+	}
+	if _, err := parseVersion(e.MinVersion); err != nil {
+		return fmt.Errorf("minVersion: %w", err)
+	}
+	if e.Label == "" && !e.Close && e.Comment == "" {
+		return errors.New("nothing to do; set label, close or comment")
+	}
+	if e.Comment != "" {
+		if e.comment, err = parseCommentTemplate("eol", e.Comment); err != nil {
+			return fmt.Errorf("comment: %w", err)
+		}
+	}
+	return nil
+}
+
+// version returns the version reported in the text, if any.
+func (e *EOL) version(text string) string {
+	m := e.pattern.FindStringSubmatch(text)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	default:
+		return m[0]
+	}
+}
+
+func (r *Runner) eolActions(ctx context.Context, base Action, i github.Issue, d Directive) ([]Action, error) {
+	e := d.EOL
+	v := e.version(i.GetBody())
+	if v == "" {
+		return nil, nil
+	}
+	pv, err := parseVersion(v)
+	if err != nil {
+		return nil, nil
+	}
+	min, _ := parseVersion(e.MinVersion)
+	if compareVersions(pv, min) >= 0 {
+		return nil, nil
+	}
+	debugf("Issue %d reports version %s, older than %s", i.GetNumber(), v, e.MinVersion)
+
+	var actions []Action
+	if e.Label != "" && !contains(i.Labels, e.Label) {
+		a := base
+		a.Kind, a.Label = ActionLabel, e.Label
+		actions = append(actions, a)
+	}
+	if i.GetState() == "closed" {
+		return actions, nil
+	}
+	if e.comment != nil {
+		data := issueCommentData(d, r.Clock.Now(), i)
+		data.Version, data.MinVersion = v, e.MinVersion
+		text, err := r.renderComment(ctx, e.comment, base.Owner, base.Repo, data)
+		if err != nil {
+			return nil, fmt.Errorf("rendering EOL comment: %w", err)
+		}
+		a := base
+		a.Kind, a.Comment, a.Reaction = ActionComment, d.comment(text), d.CommentReaction
+		actions = append(actions, a)
+	}
+	if e.Close {
+		a := base
+		a.Kind = ActionClose
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+func parseVersion(s string) ([]int, error) {
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return nil, errors.New("empty version")
+	}
+	var res []int
+	for _, f := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("bad version %q", s)
+		}
+		res = append(res, n)
+	}
+	return res, nil
+}
+
+// compareVersions returns -1, 0 or 1 as a is older, the same as or newer
+// than b. Missing components count as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
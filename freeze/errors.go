@@ -0,0 +1,78 @@
+package freeze
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/github"
+)
+
+// ConfigError is returned for invalid configurations.
+type ConfigError struct{ Err error }
+
+func (e *ConfigError) Error() string { return "configuration: " + e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// AuthError is returned when GitHub rejects our credentials.
+type AuthError struct{ Err error }
+
+func (e *AuthError) Error() string { return "authentication: " + e.Err.Error() }
+func (e *AuthError) Unwrap() error { return e.Err }
+
+// RateLimitError is returned when we've run out of API rate limit.
+type RateLimitError struct{ Err error }
+
+func (e *RateLimitError) Error() string { return "rate limited: " + e.Err.Error() }
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// APIError is returned for other GitHub API failures.
+type APIError struct{ Err error }
+
+func (e *APIError) Error() string { return e.Err.Error() }
+func (e *APIError) Unwrap() error { return e.Err }
+
+// PartialFailureError is returned when a run completed, but some repos
+// could not be processed or some actions failed.
+type PartialFailureError struct {
+	Failed int
+	// FailedActions is the number of issues with a failed action.
+	FailedActions int
+}
+
+func (e *PartialFailureError) Error() string {
+	switch {
+	case e.Failed > 0 && e.FailedActions > 0:
+		return fmt.Sprintf("%d repo(s) failed, and actions on %d issue(s)", e.Failed, e.FailedActions)
+	case e.Failed > 0:
+		return fmt.Sprintf("%d repo(s) failed", e.Failed)
+	default:
+		return fmt.Sprintf("actions on %d issue(s) failed", e.FailedActions)
+	}
+}
+
+// classifyAPIError wraps an error returned by the GitHub client in the
+// matching error category.
+func classifyAPIError(err error) error {
+	var rle *github.RateLimitError
+	var arle *github.AbuseRateLimitError
+	var er *github.ErrorResponse
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &rle), errors.As(err, &arle):
+		return &RateLimitError{err}
+	case errors.As(err, &er) && er.Response != nil && er.Response.StatusCode == http.StatusUnauthorized:
+		return &AuthError{err}
+	default:
+		return &APIError{err}
+	}
+}
+
+// isFatal returns true for errors that will not go away by moving on to the
+// next repo.
+func isFatal(err error) bool {
+	var ae *AuthError
+	var rle *RateLimitError
+	return errors.As(err, &ae) || errors.As(err, &rle)
+}
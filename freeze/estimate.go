@@ -0,0 +1,96 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+)
+
+// ageBuckets are the lower bounds, in days since last update, of the age
+// buckets in an estimate.
+var ageBuckets = []int{0, 30, 90, 180, 365}
+
+// An Estimate summarizes what applying a configuration would do, per
+// directive.
+type Estimate struct {
+	Directives []DirectiveEstimate
+}
+
+type DirectiveEstimate struct {
+	Directive string
+	// Issues is the number of issues that would be acted on.
+	Issues int
+	// Actions is the number of actions of each kind.
+	Actions map[string]int
+	// Ages counts the affected issues by days since last update.
+	Ages []AgeBucket
+}
+
+type AgeBucket struct {
+	// MinDays and MaxDays bound the bucket; MaxDays is zero for the last,
+	// open ended, bucket.
+	MinDays int
+	MaxDays int
+	Issues  int
+}
+
+func (b AgeBucket) String() string {
+	if b.MaxDays == 0 {
+		return fmt.Sprintf("%d+", b.MinDays)
+	}
+	return fmt.Sprintf("%d-%d", b.MinDays, b.MaxDays)
+}
+
+// Estimate evaluates the configuration and summarizes the resulting plan.
+func (r *Runner) Estimate(ctx context.Context, cfg Config) (Estimate, error) {
+	plan, err := r.Evaluate(ctx, cfg)
+	now := r.Clock.Now()
+
+	type issueKey struct {
+		owner, repo string
+		number      int
+	}
+	var est Estimate
+	byName := make(map[string]*DirectiveEstimate)
+	seen := make(map[string]map[issueKey]bool)
+	for _, a := range plan.Actions {
+		de, ok := byName[a.Directive]
+		if !ok {
+			est.Directives = append(est.Directives, DirectiveEstimate{
+				Directive: a.Directive,
+				Actions:   make(map[string]int),
+				Ages:      NewAgeBuckets(),
+			})
+			de = &est.Directives[len(est.Directives)-1]
+			byName[a.Directive] = de
+			seen[a.Directive] = make(map[issueKey]bool)
+		}
+		de.Actions[a.Kind]++
+
+		key := issueKey{a.Owner, a.Repo, a.Issue}
+		if seen[a.Directive][key] {
+			continue
+		}
+		seen[a.Directive][key] = true
+		de.Issues++
+		days := int(now.Sub(a.IssueUpdatedAt).Hours() / 24)
+		for i := len(de.Ages) - 1; i >= 0; i-- {
+			if days >= de.Ages[i].MinDays {
+				de.Ages[i].Issues++
+				break
+			}
+		}
+	}
+	return est, err
+}
+
+// NewAgeBuckets returns the empty age buckets used in estimates.
+func NewAgeBuckets() []AgeBucket {
+	bs := make([]AgeBucket, len(ageBuckets))
+	for i, min := range ageBuckets {
+		bs[i].MinDays = min
+		if i+1 < len(ageBuckets) {
+			bs[i].MaxDays = ageBuckets[i+1]
+		}
+	}
+	return bs
+}
@@ -0,0 +1,293 @@
+package freeze
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Explain writes a description of how each directive of the configuration
+// will be carried out: which API is used to find issues, which filters
+// are applied on our side, and which additional requests are made per
+// issue.
+func Explain(w io.Writer, cfg Config) {
+	if cfg.MaxActions > 0 {
+		fmt.Fprintf(w, "at most %d actions per run, the rest left for later runs\n", cfg.MaxActions)
+	}
+	for _, e := range cfg.Entries {
+		explainEntry(w, "", e)
+	}
+	for _, c := range cfg.Campaigns {
+		batch := ""
+		if c.BatchSize > 0 {
+			batch = fmt.Sprintf(", at most %d issues per run", c.BatchSize)
+		}
+		explainEntry(w, fmt.Sprintf("campaign %s (%s to %s%s): ", c.Name, orAny(c.Start), orAny(c.End), batch), c.Entry)
+	}
+}
+
+func explainEntry(w io.Writer, prefix string, e Entry) {
+	repos := "all repos of " + e.Owner
+	if len(e.Repos) > 0 {
+		repos = e.Owner + "/" + strings.Join(e.Repos, ", "+e.Owner+"/")
+	}
+	fmt.Fprintf(w, "%s%s\n", prefix, repos)
+
+	lc := newListCache(e.Directives)
+	for _, d := range e.Directives {
+		fmt.Fprintf(w, "  directive %s\n", d.Name)
+		lines := d.explain(lc)
+		if e.orgSearched(d) {
+			// Right after the find line.
+			lines = append(lines[:1], append([]string{fmt.Sprintf("      only in repos with matches, per one search of %q first", d.Query+" org:"+e.Owner)}, lines[1:]...)...)
+		}
+		for _, line := range lines {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	for _, d := range e.Discussions {
+		fmt.Fprintf(w, "  directive %s\n", d.Name)
+		fmt.Fprintf(w, "    find: discussions (GraphQL), least recently updated first, until updated within %d days\n", d.DaysNotUpdated)
+		var acts []string
+		if d.MarkAnswer {
+			acts = append(acts, "mark the most upvoted comment as the answer")
+		}
+		if d.Comment != "" {
+			acts = append(acts, "comment")
+		}
+		if d.Lock {
+			acts = append(acts, "lock")
+		}
+		fmt.Fprintf(w, "    actions: %s (GraphQL)\n", strings.Join(acts, ", "))
+	}
+}
+
+func (d Directive) explain(lc *listCache) []string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+
+	switch {
+	case d.UnlockAfterDays > 0:
+		add("find: issues we locked, from the state file")
+		add("per issue: get issue; unlock if locked at least %d days ago", d.UnlockAfterDays)
+		return lines
+	case d.Resurrect != nil:
+		add("find: issues we closed, from the state file")
+		add("per issue: get issue, list comments and/or reactions since closing")
+		return lines
+	case d.Query != "":
+		add("find: search API (30 requests/minute, at most 1000 results), query %q", d.Query+" repo:OWNER/REPO")
+	default:
+		state := d.State
+		if state == "" {
+			state = "open"
+		}
+		opts := "state=" + state
+		if d.SlashCommands != nil {
+			opts += fmt.Sprintf(", updated in the last %d days", d.SlashCommands.Days)
+		}
+		add("find: issue list API, %s", opts)
+		if lc != nil && lc.shared[listKey(d)] {
+			add("      listing shared with other directives of the repo")
+		}
+	}
+
+	filters := []string{"not locked"}
+	if d.DaysClosed > 0 {
+		filters = append(filters, fmt.Sprintf("closed %s %d %s", d.thresholdWords(), d.DaysClosed, d.dayWords()))
+	}
+	if d.DaysClosedByHuman > 0 {
+		filters = append(filters, fmt.Sprintf("closed by a person %s %d %s", d.thresholdWords(), d.DaysClosedByHuman, d.dayWords()))
+	}
+	if d.DaysNotUpdated > 0 {
+		what := "not updated"
+		if d.Activity == activityHuman {
+			what = "without human comments"
+		}
+		if d.NeedsInfo != "" {
+			what = fmt.Sprintf("labeled %q, without author comments", d.NeedsInfo)
+		}
+		filters = append(filters, fmt.Sprintf("%s %s %d %s", what, d.thresholdWords(), d.DaysNotUpdated, d.dayWords()))
+	}
+	if len(d.Patterns) > 0 {
+		filters = append(filters, fmt.Sprintf("title or body matching %s", strings.Join(d.Patterns, " or ")))
+	}
+	if len(d.ExemptMilestones) > 0 {
+		filters = append(filters, fmt.Sprintf("not in open milestones %s", strings.Join(d.ExemptMilestones, ", ")))
+	}
+	if d.Unlock {
+		filters = append(filters, "locked")
+	}
+	if d.When != "" {
+		filters = append(filters, fmt.Sprintf("when %q", d.When))
+	}
+	add("filter: %s", strings.Join(filters, ", "))
+
+	var calls []string
+	if d.HonorCommands {
+		calls = append(calls, "list comments for /freezebot commands")
+	}
+	if d.ExemptMembers {
+		calls = append(calls, "org members and collaborators (cached)")
+	}
+	if teamRefs(d.ExemptAuthors) || teamRefs(d.ExemptAssignees) {
+		calls = append(calls, "team members for exempt authors and assignees (cached)")
+	}
+	if len(d.ExemptIfCommentedByTeam) > 0 {
+		calls = append(calls, "list comments for team members")
+	}
+	if len(d.NoCloseFor) > 0 {
+		calls = append(calls, fmt.Sprintf("%s (cached)", strings.Join(d.NoCloseFor, " and ")))
+	}
+	if d.TrackedTasks != "" {
+		calls = append(calls, "timeline for tracking issues")
+	}
+	if d.Lock && d.LockSummary != nil {
+		calls = append(calls, "list comments for the lock summary")
+	}
+	if d.DaysClosedByHuman > 0 {
+		calls = append(calls, "timeline for who closed it, unless we did per the state file")
+	}
+	if d.whenEvents {
+		calls = append(calls, "timeline for the when condition")
+	}
+	if d.NeedsInfo != "" {
+		calls = append(calls, fmt.Sprintf("timeline for when %q was added and author comments", d.NeedsInfo))
+	}
+	if d.DaysLocked > 0 {
+		calls = append(calls, fmt.Sprintf("timeline for when locked, at least %d days ago", d.DaysLocked))
+	}
+	if d.Draft {
+		calls = append(calls, "draft status (GraphQL)")
+	}
+	if d.Pin || d.Unpin {
+		calls = append(calls, "pinned status (GraphQL)")
+	}
+	if d.IssueType != "" || len(d.IssueTypeByLabel) > 0 {
+		calls = append(calls, "issue type (GraphQL)")
+	}
+	if d.ExemptIfReferencedDays > 0 {
+		calls = append(calls, "timeline for references from other repos")
+	}
+	if d.WarnComment != "" {
+		calls = append(calls, fmt.Sprintf("timeline for when %q was added, on labeled issues", d.Label))
+	}
+	if len(d.CloseCommentByAssociation) > 0 {
+		calls = append(calls, "get issue for author association")
+	}
+	if d.SlashCommands != nil {
+		calls = append(calls, "list comments for slash commands")
+	}
+	if len(calls) > 0 {
+		add("per issue: %s", strings.Join(calls, ", "))
+	}
+
+	switch {
+	case d.Script != "":
+		add("decide: script %s", d.Script)
+	case d.Plugin != "":
+		add("decide: plugin %s", d.Plugin)
+	case d.SlashCommands != nil:
+		add("decide: slash commands from comments")
+	case d.EOL != nil:
+		add("decide: versions matching %q older than %s", d.EOL.Pattern, d.EOL.MinVersion)
+	default:
+		var acts []string
+		if d.Label != "" {
+			acts = append(acts, fmt.Sprintf("label %q", d.Label))
+		}
+		if d.Close {
+			if d.CloseComment != "" || len(d.CloseCommentByAssociation) > 0 {
+				acts = append(acts, "comment")
+			}
+			acts = append(acts, "close")
+		}
+		if d.Lock {
+			acts = append(acts, "lock")
+		}
+		if d.Unlock {
+			acts = append(acts, "unlock")
+		}
+		if len(d.AgeLabels) > 0 {
+			acts = append(acts, fmt.Sprintf("keep one of %d age labels", len(d.AgeLabels)))
+		}
+		if d.Pin {
+			acts = append(acts, "pin (GraphQL)")
+		}
+		if d.Unpin {
+			acts = append(acts, "unpin (GraphQL)")
+		}
+		if d.Unassign {
+			acts = append(acts, "unassign")
+		}
+		if len(d.Assign) > 0 {
+			acts = append(acts, "assign "+strings.Join(d.Assign, " or "))
+		}
+		if d.SetMilestone != "" {
+			acts = append(acts, fmt.Sprintf("set milestone %q", d.SetMilestone))
+		}
+		if d.IssueType != "" || len(d.IssueTypeByLabel) > 0 {
+			acts = append(acts, "set issue type (GraphQL)")
+		}
+		if d.ConvertToDiscussion != "" {
+			acts = append(acts, fmt.Sprintf("create discussion in %q and comment (GraphQL)", d.ConvertToDiscussion))
+		}
+		if d.TransferTo != "" {
+			acts = append(acts, "transfer to "+d.TransferTo)
+		}
+		if len(acts) == 0 {
+			acts = append(acts, "nothing")
+		}
+		add("actions: %s", strings.Join(acts, ", "))
+	}
+	if d.MaxActions > 0 {
+		add("at most %d actions per run, the rest left for later runs", d.MaxActions)
+	}
+	if d.Quiet {
+		if d.QuietLabels {
+			add("quiet: no comments or labels")
+		} else {
+			add("quiet: no comments")
+		}
+	}
+	if d.Workers > 1 {
+		add("workers: %d", d.Workers)
+	}
+	return lines
+}
+
+func teamRefs(list []string) bool {
+	for _, s := range list {
+		if strings.HasPrefix(s, "@") {
+			return true
+		}
+	}
+	return false
+}
+
+func (d Directive) dayWords() string {
+	words := "days"
+	if d.DayCounting == dayCountingBusiness {
+		words = "business days"
+	}
+	if len(d.FreezeWindows) > 0 {
+		words += " outside freeze windows"
+	}
+	return words
+}
+
+func (d Directive) thresholdWords() string {
+	if d.Threshold == thresholdMoreThan {
+		return "more than"
+	}
+	return "at least"
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "any"
+	}
+	return s
+}
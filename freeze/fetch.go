@@ -0,0 +1,337 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+type pageFetcher func(page int) ([]github.Issue, *github.Response, error)
+
+// pageHandler is called with each page of issues, in order, along with
+// the position of the first of them in the listing as it was before any of
+// our actions. It returns how many of the issues its actions took out of
+// the listing, and false to stop the iteration.
+type pageHandler func(offset int, issues []github.Issue) (removed int, more bool)
+
+// findIssues passes the issues matching the directive to fn, one page at a
+// time, starting at the given page.
+func (r *Runner) findIssues(ctx context.Context, owner, repo string, directive Directive, page int, lc *listCache, fn pageHandler) error {
+	if directive.Query != "" {
+		return r.findIssuesByQuery(ctx, owner, repo, directive, page, fn)
+	}
+	return r.findIssuesByList(ctx, owner, repo, directive, page, lc, fn)
+}
+
+func (r *Runner) findIssuesByList(ctx context.Context, owner, repo string, directive Directive, page int, lc *listCache, fn pageHandler) error {
+	opts := github.IssueListByRepoOptions{
+		ListOptions: github.ListOptions{
+			PerPage: perPage,
+		},
+	}
+
+	if directive.State != "" {
+		opts.State = directive.State
+	}
+	if !r.AsOf.IsZero() {
+		// Issues closed since might have been in any state back then.
+		opts.State = "all"
+	}
+	if sc := directive.SlashCommands; sc != nil {
+		// Only issues with recent comments can have new commands.
+		opts.Since = r.Clock.Now().Add(-time.Duration(sc.Days) * 24 * time.Hour)
+	}
+
+	key := listKey(directive)
+	if !r.REST {
+		l := &graphQLLister{client: r.Client, ctx: ctx, owner: owner, repo: repo, state: opts.State, since: opts.Since}
+		return r.stream(page, fn, lc.wrap(key, l.fetch))
+	}
+	fetch := lc.wrap(key, func(page int) ([]github.Issue, *github.Response, error) {
+		opts := opts
+		opts.Page = page
+		is, resp, err := r.Client.Issues.ListByRepo(ctx, owner, repo, &opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		res := make([]github.Issue, len(is))
+		for n, i := range is {
+			res[n] = *i
+		}
+		return res, resp, nil
+	})
+	if lc.shares(key) {
+		// The cached pages don't move.
+		return r.stream(page, fn, fetch)
+	}
+	return r.paginate(page, fn, fetch)
+}
+
+func (r *Runner) findIssuesByQuery(ctx context.Context, owner, repo string, directive Directive, page int, fn pageHandler) error {
+	opts := github.SearchOptions{
+		Sort:  "created",
+		Order: "asc",
+		ListOptions: github.ListOptions{
+			PerPage: perPage,
+		},
+	}
+
+	query := r.searchQuery(directive, fmt.Sprintf("repo:%s/%s", owner, repo))
+	return r.paginate(page, fn, func(page int) ([]github.Issue, *github.Response, error) {
+		opts := opts
+		opts.Page = page
+		is, resp, err := r.Client.Search.Issues(ctx, query, &opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		return is.Issues, resp, nil
+	})
+}
+
+// searchQuery returns the directive's query limited to the given scope,
+// e.g. "repo:owner/repo".
+func (r *Runner) searchQuery(directive Directive, scope string) string {
+	query := directive.Query + " " + scope
+	switch directive.ItemType {
+	case itemIssue:
+		query += " is:issue"
+	case itemPR:
+		query += " is:pr"
+	}
+	if !r.AsOf.IsZero() {
+		query += " created:<" + r.AsOf.Format("2006-01-02")
+	}
+	return query
+}
+
+// paginate passes the pages from the given one onwards to fn as they are
+// fetched. The pages are offsets into a listing that our own actions
+// change, as closing an issue moves the later ones up, so after a page
+// with removals the next fetch starts at where the first unhandled issue
+// moved to, skipping the issues before it. Until then, and when the
+// response tells us how many pages there are, later pages are fetched
+// ahead, up to PageConcurrency at a time.
+func (r *Runner) paginate(page int, fn pageHandler, fetch pageFetcher) error {
+	// offset is the position of the next unhandled issue in the listing
+	// as it is now, shift the number of issues removed before it.
+	offset, shift := (page-1)*perPage, 0
+	for {
+		page := offset/perPage + 1
+		is, resp, err := fetch(page)
+		if err != nil {
+			return err
+		}
+		if skip := offset - (page-1)*perPage; skip < len(is) {
+			is = is[skip:]
+		} else {
+			is = nil
+		}
+
+		removed, more := fn(offset+shift, is)
+		if !more || resp.NextPage == 0 {
+			return nil
+		}
+		if len(is) == 0 {
+			offset = page * perPage
+			continue
+		}
+		offset += len(is) - removed
+		shift += removed
+
+		if removed == 0 && resp.LastPage > page+1 && r.PageConcurrency > 1 {
+			moved := false
+			err := fetchPagesConcurrently(page+1, resp.LastPage, r.PageConcurrency, func(_ int, is []github.Issue) bool {
+				removed, more = fn(offset+shift, is)
+				offset += len(is) - removed
+				shift += removed
+				moved = removed > 0
+				return more && !moved
+			}, fetch)
+			if err != nil || !more || !moved {
+				return err
+			}
+		}
+	}
+}
+
+// stream is like paginate, but for cursor based listings and cached pages,
+// where acting on a page doesn't move the issues of the later ones.
+func (r *Runner) stream(page int, fn pageHandler, fetch pageFetcher) error {
+	for {
+		is, resp, err := fetch(page)
+		if err != nil {
+			return err
+		}
+		if _, more := fn((page-1)*perPage, is); !more || resp.NextPage == 0 {
+			return nil
+		}
+		page = resp.NextPage
+	}
+}
+
+// fetchPagesConcurrently fetches the pages first through last with up to
+// concurrency requests in flight, passing them to fn in page order until it
+// returns false. At most concurrency pages are held in memory waiting to be
+// handled.
+func fetchPagesConcurrently(first, last, concurrency int, fn func(page int, issues []github.Issue) bool, fetch pageFetcher) error {
+	type result struct {
+		issues []github.Issue
+		err    error
+	}
+
+	results := make([]chan result, last-first+1)
+	for n := range results {
+		results[n] = make(chan result, 1)
+	}
+
+	sem := make(chan struct{}, concurrency)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for n := range results {
+			select {
+			case sem <- struct{}{}:
+			case <-stop:
+				return
+			}
+			go func(n int) {
+				is, _, err := fetch(first + n)
+				results[n] <- result{is, err}
+			}(n)
+		}
+	}()
+
+	for n := range results {
+		res := <-results[n]
+		<-sem
+		if res.err != nil {
+			return res.err
+		}
+		if !fn(first+n, res.issues) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// listCache keeps the pages of issue listings that more than one directive
+// of a repo would fetch, so that each is fetched only once per repo.
+// Directives that act on a cached issue update it in place, see
+// applyLocally, so that later directives see the effects.
+type listCache struct {
+	mut    sync.Mutex
+	shared map[string]bool
+	pages  map[string]cachedPage
+}
+
+type cachedPage struct {
+	issues []github.Issue
+	resp   *github.Response
+}
+
+// newListCache returns a cache for the directives of a repo, or nil if no
+// listing is shared between them.
+func newListCache(directives []Directive) *listCache {
+	count := make(map[string]int)
+	for _, d := range directives {
+		if d.Query == "" && d.Resurrect == nil && d.UnlockAfterDays == 0 {
+			count[listKey(d)]++
+		}
+	}
+	shared := make(map[string]bool)
+	for key, n := range count {
+		if n > 1 {
+			shared[key] = true
+		}
+	}
+	if len(shared) == 0 {
+		return nil
+	}
+	return &listCache{shared: shared, pages: make(map[string]cachedPage)}
+}
+
+// listKey identifies the listing options of a list mode directive.
+func listKey(d Directive) string {
+	days := 0
+	if d.SlashCommands != nil {
+		days = d.SlashCommands.Days
+	}
+	return fmt.Sprintf("%s/%d", d.State, days)
+}
+
+// shares returns true if the listing is cached.
+func (c *listCache) shares(key string) bool {
+	return c != nil && c.shared[key]
+}
+
+// wrap returns a fetcher that caches the pages of shared listings. The
+// first fetch of a page not yet cached fetches the following ones as well,
+// before anything acts on it, so that the cached pages are a consistent
+// snapshot of the listing.
+func (c *listCache) wrap(key string, fetch pageFetcher) pageFetcher {
+	if !c.shares(key) {
+		return fetch
+	}
+	return func(page int) ([]github.Issue, *github.Response, error) {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		pkey := fmt.Sprintf("%s/%d", key, page)
+		if cp, ok := c.pages[pkey]; ok {
+			return cp.issues, cp.resp, nil
+		}
+
+		for p := page; ; {
+			is, resp, err := fetch(p)
+			if err != nil {
+				return nil, nil, err
+			}
+			c.pages[fmt.Sprintf("%s/%d", key, p)] = cachedPage{is, resp}
+			p = resp.NextPage
+			if _, ok := c.pages[fmt.Sprintf("%s/%d", key, p)]; ok || p == 0 {
+				break
+			}
+		}
+		cp := c.pages[pkey]
+		return cp.issues, cp.resp, nil
+	}
+}
+
+// applyLocally updates the issue to reflect the actions.
+func applyLocally(i *github.Issue, actions []Action, now time.Time) {
+	for _, a := range actions {
+		switch a.Kind {
+		case ActionClose:
+			i.State = github.String("closed")
+			i.ClosedAt = &now
+		case ActionReopen:
+			i.State = github.String("open")
+			i.ClosedAt = nil
+		case ActionLock:
+			i.Locked = github.Bool(true)
+		case ActionUnlock:
+			i.Locked = github.Bool(false)
+		case ActionUnassign:
+			i.Assignees = nil
+		case ActionAssign:
+			for _, login := range a.Assignees {
+				i.Assignees = append(i.Assignees, &github.User{Login: github.String(login)})
+			}
+		case ActionMilestone:
+			i.Milestone = &github.Milestone{Title: github.String(a.Milestone)}
+		case ActionLabel:
+			i.Labels = append(i.Labels, github.Label{Name: github.String(a.Label)})
+		case ActionUnlabel:
+			var labels []github.Label
+			for _, l := range i.Labels {
+				if l.GetName() != a.Label {
+					labels = append(labels, l)
+				}
+			}
+			i.Labels = labels
+		}
+	}
+}
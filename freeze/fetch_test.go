@@ -0,0 +1,90 @@
+package freeze
+
+import (
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+// fakeListing is a listing of issue numbers, paged like the REST API.
+type fakeListing struct {
+	numbers []int
+}
+
+func newFakeListing(n int) *fakeListing {
+	l := &fakeListing{}
+	for i := 1; i <= n; i++ {
+		l.numbers = append(l.numbers, i)
+	}
+	return l
+}
+
+func (l *fakeListing) fetch(page int) ([]github.Issue, *github.Response, error) {
+	var is []github.Issue
+	for i := (page - 1) * perPage; i < page*perPage && i < len(l.numbers); i++ {
+		is = append(is, github.Issue{Number: github.Int(l.numbers[i])})
+	}
+	resp := &github.Response{}
+	if last := (len(l.numbers) + perPage - 1) / perPage; page < last {
+		resp.NextPage, resp.LastPage = page+1, last
+	}
+	return is, resp, nil
+}
+
+func (l *fakeListing) remove(number int) {
+	for i, n := range l.numbers {
+		if n == number {
+			l.numbers = append(l.numbers[:i], l.numbers[i+1:]...)
+			return
+		}
+	}
+}
+
+func TestPaginateWithRemovals(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		l := newFakeListing(450)
+		r := &Runner{PageConcurrency: concurrency}
+		seen := make(map[int]int)
+		err := r.paginate(1, func(offset int, issues []github.Issue) (int, bool) {
+			removed := 0
+			for n, i := range issues {
+				if offset+n != i.GetNumber()-1 {
+					t.Errorf("concurrency %d: issue %d at offset %d", concurrency, i.GetNumber(), offset+n)
+				}
+				seen[i.GetNumber()]++
+				if i.GetNumber()%3 == 0 {
+					l.remove(i.GetNumber())
+					removed++
+				}
+			}
+			return removed, true
+		}, l.fetch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 450; i++ {
+			if seen[i] != 1 {
+				t.Errorf("concurrency %d: issue %d handled %d times", concurrency, i, seen[i])
+			}
+		}
+	}
+}
+
+func TestPaginateResumesAtPage(t *testing.T) {
+	l := newFakeListing(250)
+	r := &Runner{}
+	var first int
+	err := r.paginate(3, func(offset int, issues []github.Issue) (int, bool) {
+		first = issues[0].GetNumber()
+		if offset != 200 {
+			t.Errorf("offset %d, expected 200", offset)
+		}
+		return 0, false
+	}, l.fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 201 {
+		t.Errorf("first issue %d, expected 201", first)
+	}
+}
@@ -0,0 +1,229 @@
+package freeze
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// graphQLIssue is an issue or pull request as listed by the GraphQL API.
+type graphQLIssue struct {
+	ID        string
+	Number    int
+	Title     string
+	Body      string
+	State     string
+	Locked    bool
+	URL       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ClosedAt  *time.Time
+	Author    *struct {
+		Login    string
+		Typename string `json:"__typename"`
+	}
+	Labels    struct{ Nodes []struct{ Name string } }
+	Assignees struct{ Nodes []struct{ Login string } }
+	Milestone *struct {
+		Number int
+		Title  string
+	}
+	Comments struct{ TotalCount int }
+}
+
+// issue returns the fields of the REST API issue that we use.
+func (gi graphQLIssue) issue(pr bool) github.Issue {
+	state := strings.ToLower(gi.State)
+	if state == "merged" {
+		state = "closed"
+	}
+	i := github.Issue{
+		NodeID:    github.String(gi.ID),
+		Number:    github.Int(gi.Number),
+		Title:     github.String(gi.Title),
+		Body:      github.String(gi.Body),
+		State:     github.String(state),
+		Locked:    github.Bool(gi.Locked),
+		HTMLURL:   github.String(gi.URL),
+		CreatedAt: &gi.CreatedAt,
+		UpdatedAt: &gi.UpdatedAt,
+		ClosedAt:  gi.ClosedAt,
+		Comments:  github.Int(gi.Comments.TotalCount),
+	}
+	if a := gi.Author; a != nil {
+		u := &github.User{Login: github.String(a.Login)}
+		if a.Typename == "Bot" {
+			// REST has the [bot] suffix, GraphQL doesn't.
+			u.Login = github.String(a.Login + "[bot]")
+			u.Type = github.String("Bot")
+		}
+		i.User = u
+	}
+	for _, l := range gi.Labels.Nodes {
+		i.Labels = append(i.Labels, github.Label{Name: github.String(l.Name)})
+	}
+	for _, a := range gi.Assignees.Nodes {
+		i.Assignees = append(i.Assignees, &github.User{Login: github.String(a.Login)})
+	}
+	if m := gi.Milestone; m != nil {
+		i.Milestone = &github.Milestone{Number: github.Int(m.Number), Title: github.String(m.Title)}
+	}
+	if pr {
+		i.PullRequestLinks = &github.PullRequestLinks{HTMLURL: github.String(gi.URL)}
+	}
+	return i
+}
+
+// The issues and pull requests queries differ in the connection, the
+// state type and in that only issues can be filtered by update time.
+const (
+	graphQLIssuesQuery = `query($owner: String!, $repo: String!, $cursor: String, $states: [IssueState!], $since: DateTime, $order: IssueOrderField!, $full: Boolean!) {
+		repository(owner: $owner, name: $repo) {
+			issues(first: 100, after: $cursor, states: $states, filterBy: {since: $since}, orderBy: {field: $order, direction: DESC}) {
+				nodes { updatedAt ` + graphQLIssueFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+		}
+	}`
+	graphQLPullRequestsQuery = `query($owner: String!, $repo: String!, $cursor: String, $states: [PullRequestState!], $order: IssueOrderField!, $full: Boolean!) {
+		repository(owner: $owner, name: $repo) {
+			pullRequests(first: 100, after: $cursor, states: $states, orderBy: {field: $order, direction: DESC}) {
+				nodes { updatedAt ` + graphQLIssueFields + ` }
+				pageInfo { hasNextPage endCursor }
+			}
+		}
+	}`
+	graphQLIssueFields = `... @include(if: $full) {
+		id number title body state locked url createdAt closedAt
+		author { login __typename }
+		labels(first: 100) { nodes { name } }
+		assignees(first: 20) { nodes { login } }
+		milestone { number title }
+		comments { totalCount }
+	}`
+)
+
+// graphQLLister lists the issues of a repo followed by its pull requests,
+// a hundred per request with everything a directive looks at. GraphQL
+// pages are reached by cursor, so the lister remembers where each page
+// starts and pages must be fetched in order; resuming at a later page
+// walks the cursors up to it first, which is cheap as it skips the issue
+// fields.
+type graphQLLister struct {
+	client      *github.Client
+	ctx         context.Context
+	owner, repo string
+	// state is as for the REST API: "open", "closed" or "all".
+	state string
+	// since, if set, limits the listing to issues updated after it.
+	since  time.Time
+	starts []pageStart // starts[n] is where page n+1 starts
+}
+
+type pageStart struct {
+	prs    bool
+	cursor *string
+}
+
+func (l *graphQLLister) fetch(page int) ([]github.Issue, *github.Response, error) {
+	if l.starts == nil {
+		l.starts = []pageStart{{}}
+	}
+	for len(l.starts) < page {
+		_, next, more, err := l.query(l.starts[len(l.starts)-1], false)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !more {
+			// The listing has shrunk since the checkpoint.
+			return nil, &github.Response{}, nil
+		}
+		l.starts = append(l.starts, next)
+	}
+
+	is, next, more, err := l.query(l.starts[page-1], true)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp := &github.Response{}
+	if more {
+		if len(l.starts) == page {
+			l.starts = append(l.starts, next)
+		}
+		resp.NextPage = page + 1
+	}
+	return is, resp, nil
+}
+
+// query fetches the page starting at start, returning the issues if full
+// is set, and where the next page starts if there is one.
+func (l *graphQLLister) query(start pageStart, full bool) ([]github.Issue, pageStart, bool, error) {
+	vars := map[string]interface{}{
+		"owner":  l.owner,
+		"repo":   l.repo,
+		"cursor": start.cursor,
+		"order":  "CREATED_AT",
+		"full":   full,
+	}
+	switch l.state {
+	case "", "open":
+		vars["states"] = []string{"OPEN"}
+	case "closed":
+		vars["states"] = []string{"CLOSED"}
+		if start.prs {
+			vars["states"] = []string{"CLOSED", "MERGED"}
+		}
+	}
+	query := graphQLIssuesQuery
+	if start.prs {
+		query = graphQLPullRequestsQuery
+	}
+	if !l.since.IsZero() {
+		// Pull requests can't be filtered by update time, so we list
+		// most recently updated first and stop at the first older one.
+		vars["order"] = "UPDATED_AT"
+		if !start.prs {
+			vars["since"] = l.since
+		}
+	}
+
+	type connection struct {
+		Nodes    []graphQLIssue
+		PageInfo struct {
+			HasNextPage bool
+			EndCursor   string
+		}
+	}
+	var res struct {
+		Repository struct {
+			Issues       connection
+			PullRequests connection
+		}
+	}
+	if err := graphQL(l.ctx, l.client, query, vars, &res); err != nil {
+		return nil, pageStart{}, false, err
+	}
+	conn := res.Repository.Issues
+	if start.prs {
+		conn = res.Repository.PullRequests
+	}
+
+	var is []github.Issue
+	for _, gi := range conn.Nodes {
+		if start.prs && !l.since.IsZero() && gi.UpdatedAt.Before(l.since) {
+			return is, pageStart{}, false, nil
+		}
+		if full {
+			is = append(is, gi.issue(start.prs))
+		}
+	}
+	switch {
+	case conn.PageInfo.HasNextPage:
+		return is, pageStart{prs: start.prs, cursor: &conn.PageInfo.EndCursor}, true, nil
+	case !start.prs:
+		return is, pageStart{prs: true}, true, nil
+	}
+	return is, pageStart{}, false, nil
+}
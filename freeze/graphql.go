@@ -0,0 +1,88 @@
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// graphQL runs a query or mutation against the GitHub GraphQL API and
+// decodes the returned data into res, which may be nil.
+func graphQL(ctx context.Context, client *github.Client, query string, vars map[string]interface{}, res interface{}) error {
+	req, err := client.NewRequest("POST", graphQLPath(client), map[string]interface{}{
+		"query":     query,
+		"variables": vars,
+	})
+	if err != nil {
+		return err
+	}
+	// Issue types are still a preview feature.
+	req.Header.Set("GraphQL-Features", "issue_types")
+
+	var resp struct {
+		Data   json.RawMessage
+		Errors []struct {
+			Message string
+		}
+	}
+	if _, err := client.Do(ctx, req, &resp); err != nil {
+		return err
+	}
+	if len(resp.Errors) > 0 {
+		msgs := make([]string, len(resp.Errors))
+		for i, e := range resp.Errors {
+			msgs[i] = e.Message
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, res)
+}
+
+// isDraft returns true if the pull request with the given GraphQL ID is a
+// draft. The client library doesn't know about drafts.
+func isDraft(ctx context.Context, client *github.Client, nodeID string) (bool, error) {
+	var res struct {
+		Node struct {
+			IsDraft bool
+		}
+	}
+	err := graphQL(ctx, client, `query($id: ID!) {
+		node(id: $id) { ... on PullRequest { isDraft } }
+	}`, map[string]interface{}{"id": nodeID}, &res)
+	if err != nil {
+		return false, classifyAPIError(err)
+	}
+	return res.Node.IsDraft, nil
+}
+
+// isPinned returns true if the issue with the given GraphQL ID is pinned.
+func isPinned(ctx context.Context, client *github.Client, nodeID string) (bool, error) {
+	var res struct {
+		Node struct {
+			IsPinned bool
+		}
+	}
+	err := graphQL(ctx, client, `query($id: ID!) {
+		node(id: $id) { ... on Issue { isPinned } }
+	}`, map[string]interface{}{"id": nodeID}, &res)
+	if err != nil {
+		return false, classifyAPIError(err)
+	}
+	return res.Node.IsPinned, nil
+}
+
+// graphQLPath returns the GraphQL endpoint relative to the client's base
+// URL. On GitHub Enterprise Server the REST API is under /api/v3/ while
+// GraphQL is at /api/graphql.
+func graphQLPath(client *github.Client) string {
+	if strings.HasSuffix(client.BaseURL.Path, "/api/v3/") {
+		return "../graphql"
+	}
+	return "graphql"
+}
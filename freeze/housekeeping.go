@@ -0,0 +1,142 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	housekeepingRelease = "release"
+	housekeepingStart   = "<!-- freezebot housekeeping -->"
+	housekeepingEnd     = "<!-- /freezebot housekeeping -->"
+)
+
+func validHousekeeping(s string) error {
+	if s == "" || s == housekeepingRelease {
+		return nil
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(s, "#")); err != nil || n <= 0 || !strings.HasPrefix(s, "#") {
+		return fmt.Errorf("housekeeping %q is neither \"release\" nor an issue \"#number\"", s)
+	}
+	return nil
+}
+
+// Housekeeping updates, for each entry with Housekeeping set, a section of
+// the draft release or designated issue listing the issues we closed
+// since the latest release. It relies on the closed issues recorded in
+// the state. With dryRun, the updates are only logged.
+func (r *Runner) Housekeeping(ctx context.Context, cfg Config, dryRun bool) error {
+	if r.State == nil {
+		return errors.New("housekeeping requires a state")
+	}
+	for _, e := range cfg.Entries {
+		if e.Housekeeping == "" {
+			continue
+		}
+		repos, err := r.repoNames(ctx, e)
+		if err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			if err := r.housekeepRepo(ctx, e.Owner, repo, e.Housekeeping, dryRun); err != nil {
+				return fmt.Errorf("%s/%s: %w", e.Owner, repo, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) housekeepRepo(ctx context.Context, owner, repo, target string, dryRun bool) error {
+	var since time.Time
+	rel, resp, err := r.Client.Repositories.GetLatestRelease(ctx, owner, repo)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return classifyAPIError(err)
+	}
+	if rel != nil {
+		since = rel.GetPublishedAt().Time
+	}
+
+	prefix := owner + "/" + repo + "#"
+	var numbers []int
+	for key, closedAt := range r.State.Closed {
+		if !strings.HasPrefix(key, prefix) || closedAt.Before(since) {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil {
+			numbers = append(numbers, n)
+		}
+	}
+	if len(numbers) == 0 {
+		return nil
+	}
+	sort.Ints(numbers)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s\n### Housekeeping\n\n%d stale issues were closed automatically:\n\n", housekeepingStart, len(numbers))
+	for _, n := range numbers {
+		fmt.Fprintf(&sb, "- #%d\n", n)
+	}
+	sb.WriteString(housekeepingEnd)
+	section := sb.String()
+
+	dry := ""
+	if dryRun {
+		dry = "DRY-RUN: "
+	}
+
+	if target == housekeepingRelease {
+		rels, _, err := r.Client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{PerPage: perPage})
+		if err != nil {
+			return classifyAPIError(err)
+		}
+		for _, rel := range rels {
+			if !rel.GetDraft() {
+				continue
+			}
+			infof("%sUpdating housekeeping section of draft release %q in %s/%s", dry, rel.GetName(), owner, repo)
+			if dryRun {
+				return nil
+			}
+			body := withSection(rel.GetBody(), section)
+			_, _, err := r.Client.Repositories.EditRelease(ctx, owner, repo, rel.GetID(), &github.RepositoryRelease{Body: &body})
+			return classifyAPIError(err)
+		}
+		warnf("No draft release in %s/%s for the housekeeping section", owner, repo)
+		return nil
+	}
+
+	number, _ := strconv.Atoi(strings.TrimPrefix(target, "#"))
+	i, _, err := r.Client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		return classifyAPIError(err)
+	}
+	infof("%sUpdating housekeeping section of issue %d in %s/%s", dry, number, owner, repo)
+	if dryRun {
+		return nil
+	}
+	body := withSection(i.GetBody(), section)
+	_, _, err = r.Client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{Body: &body})
+	return classifyAPIError(err)
+}
+
+// withSection replaces the housekeeping section of the body, or appends it
+// if there is none.
+func withSection(body, section string) string {
+	start := strings.Index(body, housekeepingStart)
+	end := strings.Index(body, housekeepingEnd)
+	if start >= 0 && end > start {
+		return body[:start] + section + body[end+len(housekeepingEnd):]
+	}
+	if body != "" {
+		body += "\n\n"
+	}
+	return body + section
+}
@@ -0,0 +1,92 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// issueType returns the type the directive gives the issue, if any.
+func (d Directive) issueType(i github.Issue) string {
+	for _, l := range i.Labels {
+		if typ, ok := d.IssueTypeByLabel[l.GetName()]; ok {
+			return typ
+		}
+	}
+	return d.IssueType
+}
+
+// issueType returns the name of the issue type of the issue with the given
+// GraphQL ID, or the empty string if it has none.
+func issueType(ctx context.Context, client *github.Client, nodeID string) (string, error) {
+	var res struct {
+		Node struct {
+			IssueType *struct {
+				Name string
+			}
+		}
+	}
+	err := graphQL(ctx, client, `query($id: ID!) {
+		node(id: $id) { ... on Issue { issueType { name } } }
+	}`, map[string]interface{}{"id": nodeID}, &res)
+	if err != nil {
+		return "", classifyAPIError(err)
+	}
+	if res.Node.IssueType == nil {
+		return "", nil
+	}
+	return res.Node.IssueType.Name, nil
+}
+
+func (s *GitHubSink) setIssueType(ctx context.Context, a Action) error {
+	typeID, err := s.issueTypeID(ctx, a.Owner, a.IssueType)
+	if err != nil {
+		return fmt.Errorf("looking up issue type %q: %w", a.IssueType, err)
+	}
+	return retry(ctx, "Setting type of", a.Issue, func() error {
+		return graphQL(ctx, s.Client, `mutation($issue: ID!, $type: ID!) {
+			updateIssueIssueType(input: {issueId: $issue, issueTypeId: $type}) { issue { number } }
+		}`, map[string]interface{}{"issue": a.IssueNodeID, "type": typeID}, nil)
+	})
+}
+
+func (s *GitHubSink) issueTypeID(ctx context.Context, org, name string) (string, error) {
+	key := org + ":" + name
+	s.mut.Lock()
+	id, ok := s.typeIDs[key]
+	s.mut.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var res struct {
+		Organization struct {
+			IssueTypes struct {
+				Nodes []struct {
+					ID   string
+					Name string
+				}
+			}
+		}
+	}
+	err := graphQL(ctx, s.Client, `query($org: String!) {
+		organization(login: $org) { issueTypes(first: 100) { nodes { id name } } }
+	}`, map[string]interface{}{"org": org}, &res)
+	if err != nil {
+		return "", classifyAPIError(err)
+	}
+	for _, t := range res.Organization.IssueTypes.Nodes {
+		if strings.EqualFold(t.Name, name) {
+			s.mut.Lock()
+			if s.typeIDs == nil {
+				s.typeIDs = make(map[string]string)
+			}
+			s.typeIDs[key] = t.ID
+			s.mut.Unlock()
+			return t.ID, nil
+		}
+	}
+	return "", fmt.Errorf("no such issue type in %s", org)
+}
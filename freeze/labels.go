@@ -0,0 +1,134 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// defaultLabelColor is the color of labels we create for directives that
+// aren't in the entry's label set.
+const defaultLabelColor = "ededed"
+
+var labelColorExp = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+// A LabelSpec is a label as it should be in every repo of an entry.
+type LabelSpec struct {
+	Name string
+	// Color is six hex digits, as in "d73a4a".
+	Color       string
+	Description string
+}
+
+func (l *LabelSpec) validate() error {
+	if l.Name == "" {
+		return errors.New("every label must set `name`")
+	}
+	l.Color = strings.ToLower(strings.TrimPrefix(l.Color, "#"))
+	if l.Color == "" {
+		l.Color = defaultLabelColor
+	}
+	if !labelColorExp.MatchString(l.Color) {
+		return fmt.Errorf("label %q: color %q is not six hex digits", l.Name, l.Color)
+	}
+	return nil
+}
+
+// labelSet returns the labels the entry wants in each repo: its own label
+// set, and the labels its directives set.
+func (e Entry) labelSet() []LabelSpec {
+	labels := append([]LabelSpec{}, e.Labels...)
+	seen := make(map[string]bool)
+	for _, l := range labels {
+		seen[strings.ToLower(l.Name)] = true
+	}
+	for _, d := range e.Directives {
+		if d.Label != "" && !seen[strings.ToLower(d.Label)] {
+			labels = append(labels, LabelSpec{Name: d.Label, Color: defaultLabelColor})
+			seen[strings.ToLower(d.Label)] = true
+		}
+	}
+	return labels
+}
+
+// SyncLabels creates the labels of each entry's label set in its repos,
+// and updates the color and description of existing ones to match. Labels
+// that only directives use are created if missing but otherwise left
+// alone, as are labels not in the set. With dryRun, the changes are only
+// logged.
+func (r *Runner) SyncLabels(ctx context.Context, cfg Config, dryRun bool) error {
+	for _, e := range cfg.Entries {
+		labels := e.labelSet()
+		if len(labels) == 0 {
+			continue
+		}
+		repos, err := r.repoNames(ctx, e)
+		if err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			if err := r.syncRepoLabels(ctx, e, repo, labels, dryRun); err != nil {
+				return fmt.Errorf("%s/%s: %w", e.Owner, repo, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) syncRepoLabels(ctx context.Context, e Entry, repo string, labels []LabelSpec, dryRun bool) error {
+	existing := make(map[string]*github.Label)
+	opts := &github.ListOptions{PerPage: perPage}
+	for {
+		ls, resp, err := r.Client.Issues.ListLabels(ctx, e.Owner, repo, opts)
+		if err != nil {
+			return classifyAPIError(err)
+		}
+		for _, l := range ls {
+			existing[strings.ToLower(l.GetName())] = l
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	declared := make(map[string]bool)
+	for _, l := range e.Labels {
+		declared[strings.ToLower(l.Name)] = true
+	}
+
+	prefix := ""
+	if dryRun {
+		prefix = "DRY-RUN: "
+	}
+	for _, l := range labels {
+		want := &github.Label{
+			Name:        github.String(l.Name),
+			Color:       github.String(l.Color),
+			Description: github.String(l.Description),
+		}
+		cur, ok := existing[strings.ToLower(l.Name)]
+		switch {
+		case !ok:
+			infof("%sCreating label %q in %s/%s", prefix, l.Name, e.Owner, repo)
+			if !dryRun {
+				if _, _, err := r.Client.Issues.CreateLabel(ctx, e.Owner, repo, want); err != nil {
+					return classifyAPIError(err)
+				}
+			}
+		case declared[strings.ToLower(l.Name)] && (cur.GetName() != l.Name || !strings.EqualFold(cur.GetColor(), l.Color) || cur.GetDescription() != l.Description):
+			infof("%sUpdating label %q in %s/%s", prefix, l.Name, e.Owner, repo)
+			if !dryRun {
+				if _, _, err := r.Client.Issues.EditLabel(ctx, e.Owner, repo, url.PathEscape(cur.GetName()), want); err != nil {
+					return classifyAPIError(err)
+				}
+			}
+		}
+	}
+	return nil
+}
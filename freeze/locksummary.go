@@ -0,0 +1,125 @@
+package freeze
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// A LockSummary makes the directive comment with a summary of the thread
+// before locking an issue. The summary comes from running Command, which
+// gets the comment data (see commentData) as JSON on stdin and writes the
+// summary on stdout, or else from executing Template.
+type LockSummary struct {
+	Command []string
+	// Template is like CloseComment, with the recent comments available
+	// as {{.Comments}}.
+	Template string
+	// Comments is how many of the most recent comments to pass on; 20 by
+	// default.
+	Comments int
+	// Timeout limits how long the command may run; "1m" by default.
+	Timeout string
+
+	template *template.Template
+	timeout  time.Duration
+}
+
+// commentInfo is what summaries get to see of each comment.
+type commentInfo struct {
+	Author      string
+	Association string
+	Created     time.Time
+	Body        string
+}
+
+func (s *LockSummary) validate() error {
+	if (len(s.Command) == 0) == (s.Template == "") {
+		return errors.New("exactly one of command and template must be set")
+	}
+	if s.Comments == 0 {
+		s.Comments = 20
+	}
+	s.timeout = time.Minute
+	if s.Timeout != "" {
+		var err error
+		if s.timeout, err = time.ParseDuration(s.Timeout); err != nil {
+			return fmt.Errorf("timeout: %w", err)
+		}
+	}
+	if s.Template != "" {
+		tmpl, err := parseCommentTemplate("lockSummary", s.Template)
+		if err != nil {
+			return err
+		}
+		s.template = tmpl
+	}
+	return nil
+}
+
+// lockSummary returns the summary comment for the issue.
+func (r *Runner) lockSummary(ctx context.Context, owner, repo string, i github.Issue, directive Directive) (string, error) {
+	s := directive.LockSummary
+	data := issueCommentData(directive, r.Clock.Now(), i)
+	comments, err := recentComments(ctx, r.Client, owner, repo, i.GetNumber(), s.Comments)
+	if err != nil {
+		return "", err
+	}
+	data.Comments = comments
+
+	if s.template != nil {
+		return r.renderComment(ctx, s.template, owner, repo, data)
+	}
+
+	in, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", s.Command[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// recentComments returns the last n comments on the issue, oldest first.
+func recentComments(ctx context.Context, client *github.Client, owner, repo string, number, n int) ([]commentInfo, error) {
+	var res []commentInfo
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return nil, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			res = append(res, commentInfo{
+				Author:      c.GetUser().GetLogin(),
+				Association: c.GetAuthorAssociation(),
+				Created:     c.GetCreatedAt(),
+				Body:        c.GetBody(),
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	if len(res) > n {
+		res = res[len(res)-n:]
+	}
+	return res, nil
+}
@@ -0,0 +1,39 @@
+package freeze
+
+import "log"
+
+// A LogLevel says which messages are logged: those at the level and above.
+type LogLevel int
+
+const (
+	// LogDebug adds why each issue was skipped.
+	LogDebug LogLevel = iota
+	// LogInfo logs the actions and the progress of the run.
+	LogInfo
+	// LogWarn logs only warnings and errors.
+	LogWarn
+)
+
+var logLevel = LogInfo
+
+// SetLogLevel sets the level of the messages to log, LogInfo by default.
+// Call it before running.
+func SetLogLevel(level LogLevel) {
+	logLevel = level
+}
+
+func debugf(format string, args ...interface{}) {
+	if logLevel <= LogDebug {
+		log.Printf(format, args...)
+	}
+}
+
+func infof(format string, args ...interface{}) {
+	if logLevel <= LogInfo {
+		log.Printf(format, args...)
+	}
+}
+
+func warnf(format string, args ...interface{}) {
+	log.Printf(format, args...)
+}
@@ -0,0 +1,326 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// A MemberList is a cached list of user logins, such as the members of an
+// org or the collaborators on a repo.
+type MemberList struct {
+	Fetched time.Time
+	Logins  []string
+}
+
+// memberCache keeps member lists for the duration of a run, and across
+// runs in the state when the runner has a MembershipTTL.
+type memberCache struct {
+	mut   sync.Mutex
+	lists map[string]map[string]bool
+}
+
+type userLister func(opts github.ListOptions) ([]*github.User, *github.Response, error)
+
+// memberSet returns the set of lowercased logins for the key, getting them
+// with the given function unless cached.
+func (r *Runner) memberSet(ctx context.Context, key string, fetch func() ([]string, error)) (map[string]bool, error) {
+	c := &r.members
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if set, ok := c.lists[key]; ok {
+		return set, nil
+	}
+	if c.lists == nil {
+		c.lists = make(map[string]map[string]bool)
+	}
+
+	now := r.Clock.Now()
+	if r.MembershipTTL > 0 {
+		var ml MemberList
+		var ok bool
+		r.State.with(func() { ml, ok = r.State.Members[key] })
+		if ok && now.Sub(ml.Fetched) < r.MembershipTTL {
+			c.lists[key] = loginSet(ml.Logins)
+			return c.lists[key], nil
+		}
+	}
+
+	logins, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	for i := range logins {
+		logins[i] = strings.ToLower(logins[i])
+	}
+	sort.Strings(logins)
+
+	c.lists[key] = loginSet(logins)
+	if r.MembershipTTL > 0 {
+		r.State.with(func() { r.State.Members[key] = MemberList{Fetched: now, Logins: logins} })
+	}
+	return c.lists[key], nil
+}
+
+// listUsers returns the logins from all pages of a REST listing. A 404
+// gives an empty list, as for owners that are users rather than orgs.
+func listUsers(list userLister) ([]string, error) {
+	var logins []string
+	opts := github.ListOptions{PerPage: perPage}
+	for {
+		users, resp, err := list(opts)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return logins, nil
+			}
+			return nil, classifyAPIError(err)
+		}
+		for _, u := range users {
+			logins = append(logins, u.GetLogin())
+		}
+		if resp.NextPage == 0 {
+			return logins, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func loginSet(logins []string) map[string]bool {
+	set := make(map[string]bool, len(logins))
+	for _, l := range logins {
+		set[l] = true
+	}
+	return set
+}
+
+// isMember returns true if the user is a member of the owning org or a
+// collaborator on the repo.
+func (r *Runner) isMember(ctx context.Context, owner, repo, login string) (bool, error) {
+	login = strings.ToLower(login)
+
+	members, err := r.memberSet(ctx, "org:"+owner, func() ([]string, error) {
+		return listUsers(func(opts github.ListOptions) ([]*github.User, *github.Response, error) {
+			return r.Client.Organizations.ListMembers(ctx, owner, &github.ListMembersOptions{ListOptions: opts})
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	if members[login] {
+		return true, nil
+	}
+
+	collabs, err := r.memberSet(ctx, "repo:"+owner+"/"+repo, func() ([]string, error) {
+		return listUsers(func(opts github.ListOptions) ([]*github.User, *github.Response, error) {
+			return r.Client.Repositories.ListCollaborators(ctx, owner, repo, &github.ListCollaboratorsOptions{ListOptions: opts})
+		})
+	})
+	if err != nil {
+		return false, err
+	}
+	return collabs[login], nil
+}
+
+// teamMembers returns the members of the "org/slug" team.
+func (r *Runner) teamMembers(ctx context.Context, team string) (map[string]bool, error) {
+	return r.memberSet(ctx, "team:"+team, func() ([]string, error) {
+		return listUsers(func(opts github.ListOptions) ([]*github.User, *github.Response, error) {
+			org, slug, _ := strings.Cut(team, "/")
+			u := fmt.Sprintf("orgs/%s/teams/%s/members?per_page=%d&page=%d", org, slug, opts.PerPage, opts.Page)
+			req, err := r.Client.NewRequest("GET", u, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			var users []*github.User
+			resp, err := r.Client.Do(ctx, req, &users)
+			return users, resp, err
+		})
+	})
+}
+
+// listed returns the first of the logins that is one of the users or a
+// member of one of the "@team" or "@org/team" teams in the list, or the
+// empty string if none is.
+func (r *Runner) listed(ctx context.Context, owner string, logins, list []string) (string, error) {
+	for _, entry := range list {
+		team, isTeam := strings.CutPrefix(entry, "@")
+		if !isTeam {
+			for _, login := range logins {
+				if strings.EqualFold(login, entry) {
+					return login, nil
+				}
+			}
+			continue
+		}
+		if !strings.Contains(team, "/") {
+			team = owner + "/" + team
+		}
+		set, err := r.teamMembers(ctx, team)
+		if err != nil {
+			return "", fmt.Errorf("listing team %s: %w", team, err)
+		}
+		for _, login := range logins {
+			if set[strings.ToLower(login)] {
+				return login, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// commentedByTeam returns true if a member of any of the teams commented on
+// the issue since the given time.
+func (r *Runner) commentedByTeam(ctx context.Context, owner, repo string, number int, teams []string, since time.Time) (bool, error) {
+	var sets []map[string]bool
+	for _, team := range teams {
+		set, err := r.teamMembers(ctx, team)
+		if err != nil {
+			return false, fmt.Errorf("listing team %s: %w", team, err)
+		}
+		sets = append(sets, set)
+	}
+
+	opts := &github.IssueListCommentsOptions{Since: since, ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := r.Client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return false, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			if c.GetCreatedAt().Before(since) {
+				continue
+			}
+			login := strings.ToLower(c.GetUser().GetLogin())
+			for _, set := range sets {
+				if set[login] {
+					return true, nil
+				}
+			}
+		}
+		if resp.NextPage == 0 {
+			return false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// isContributor returns true if the user has commits in the repo.
+func (r *Runner) isContributor(ctx context.Context, owner, repo, login string) (bool, error) {
+	set, err := r.memberSet(ctx, "contributors:"+owner+"/"+repo, func() ([]string, error) {
+		var logins []string
+		opts := &github.ListContributorsOptions{ListOptions: github.ListOptions{PerPage: perPage}}
+		for {
+			cs, resp, err := r.Client.Repositories.ListContributors(ctx, owner, repo, opts)
+			if err != nil {
+				return nil, classifyAPIError(err)
+			}
+			for _, c := range cs {
+				logins = append(logins, c.GetLogin())
+			}
+			if resp.NextPage == 0 {
+				return logins, nil
+			}
+			opts.Page = resp.NextPage
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return set[strings.ToLower(login)], nil
+}
+
+// isSponsor returns true if the user sponsors the owner.
+func (r *Runner) isSponsor(ctx context.Context, owner, login string) (bool, error) {
+	set, err := r.memberSet(ctx, "sponsors:"+owner, func() ([]string, error) {
+		var logins []string
+		var cursor *string
+		for {
+			var res struct {
+				RepositoryOwner struct {
+					SponsorshipsAsMaintainer struct {
+						Nodes []struct {
+							SponsorEntity struct {
+								Login string
+							}
+						}
+						PageInfo struct {
+							HasNextPage bool
+							EndCursor   string
+						}
+					}
+				}
+			}
+			err := graphQL(ctx, r.Client, `query($owner: String!, $after: String) {
+				repositoryOwner(login: $owner) {
+					... on Sponsorable {
+						sponsorshipsAsMaintainer(first: 100, after: $after) {
+							nodes { sponsorEntity { ... on User { login } ... on Organization { login } } }
+							pageInfo { hasNextPage endCursor }
+						}
+					}
+				}
+			}`, map[string]interface{}{"owner": owner, "after": cursor}, &res)
+			if err != nil {
+				return nil, classifyAPIError(err)
+			}
+			sp := res.RepositoryOwner.SponsorshipsAsMaintainer
+			for _, n := range sp.Nodes {
+				logins = append(logins, n.SponsorEntity.Login)
+			}
+			if !sp.PageInfo.HasNextPage {
+				return logins, nil
+			}
+			cursor = &sp.PageInfo.EndCursor
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	return set[strings.ToLower(login)], nil
+}
+
+// courtesy returns the first of the author kinds that the user belongs
+// to, or the empty string.
+func (r *Runner) courtesy(ctx context.Context, owner, repo, login string, kinds []string) (string, error) {
+	for _, kind := range kinds {
+		var ok bool
+		var err error
+		switch kind {
+		case authorContributors:
+			ok, err = r.isContributor(ctx, owner, repo, login)
+		case authorSponsors:
+			ok, err = r.isSponsor(ctx, owner, login)
+		}
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return kind, nil
+		}
+	}
+	return "", nil
+}
+
+// authorAssociation returns the issue author's association with the repo
+// ("CONTRIBUTOR", "NONE", ...), which our version of the GitHub client
+// doesn't decode.
+func authorAssociation(ctx context.Context, client *github.Client, owner, repo string, number int) (string, error) {
+	req, err := client.NewRequest("GET", fmt.Sprintf("repos/%s/%s/issues/%d", owner, repo, number), nil)
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		AuthorAssociation string `json:"author_association"`
+	}
+	if _, err := client.Do(ctx, req, &res); err != nil {
+		return "", classifyAPIError(err)
+	}
+	return res.AuthorAssociation, nil
+}
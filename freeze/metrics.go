@@ -0,0 +1,19 @@
+package freeze
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricActions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "freezebot",
+		Name:      "actions_total",
+		Help:      "Number of actions performed, per directive and action.",
+	}, []string{"owner", "repo", "directive", "action"})
+	metricMatchingIssues = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "freezebot",
+		Name:      "matching_issues",
+		Help:      "Number of issues matching the directive, as of the last complete pass over the repo.",
+	}, []string{"owner", "repo", "directive"})
+)
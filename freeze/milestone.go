@@ -0,0 +1,55 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+func (s *GitHubSink) setMilestone(ctx context.Context, a Action) error {
+	number, err := s.milestoneNumber(ctx, a.Owner, a.Repo, a.Milestone)
+	if err != nil {
+		return fmt.Errorf("looking up milestone %q: %w", a.Milestone, err)
+	}
+	return retry(ctx, "Setting milestone of", a.Issue, func() error {
+		_, _, err := s.Client.Issues.Edit(ctx, a.Owner, a.Repo, a.Issue, &github.IssueRequest{Milestone: &number})
+		return err
+	})
+}
+
+// milestoneNumber returns the number of the open milestone with the given
+// title.
+func (s *GitHubSink) milestoneNumber(ctx context.Context, owner, repo, title string) (int, error) {
+	key := owner + "/" + repo + ":" + title
+	s.mut.Lock()
+	number, ok := s.milestones[key]
+	s.mut.Unlock()
+	if ok {
+		return number, nil
+	}
+
+	opts := &github.MilestoneListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		ms, resp, err := s.Client.Issues.ListMilestones(ctx, owner, repo, opts)
+		if err != nil {
+			return 0, classifyAPIError(err)
+		}
+		for _, m := range ms {
+			if strings.EqualFold(m.GetTitle(), title) {
+				s.mut.Lock()
+				if s.milestones == nil {
+					s.milestones = make(map[string]int)
+				}
+				s.milestones[key] = m.GetNumber()
+				s.mut.Unlock()
+				return m.GetNumber(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return 0, fmt.Errorf("no such open milestone in %s/%s", owner, repo)
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,113 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// An Observation is a lifecycle event on an issue selected by a directive,
+// recorded without acting, to audit what someone else (such as another
+// bot) does to the issues.
+type Observation struct {
+	Owner     string
+	Repo      string
+	Issue     int
+	Directive string
+	// Kind is the event: "closed", "reopened", "locked", "unlocked",
+	// "labeled" or "unlabeled".
+	Kind  string
+	Actor string
+	At    time.Time
+	Label string `json:",omitempty"`
+	// Matches is whether the directive matches the issue now.
+	Matches bool
+}
+
+var observedEvents = map[string]bool{
+	"closed": true, "reopened": true, "locked": true, "unlocked": true,
+	"labeled": true, "unlabeled": true,
+}
+
+// Observe goes through the issues selected by the directives, as Run
+// does, but instead of acting passes the lifecycle events of each issue
+// to fn. Only events at or after since are passed.
+func (r *Runner) Observe(ctx context.Context, cfg Config, since time.Time, fn func(Observation) error) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	observeRepo := func(owner, repo string, directives []Directive) error {
+		infof("Observing %s/%s", owner, repo)
+		for _, d := range directives {
+			if d.Resurrect != nil || d.UnlockAfterDays > 0 {
+				continue
+			}
+			if d.Query == "" {
+				// Closed issues are the interesting ones.
+				d.State = "all"
+			}
+			var obsErr error
+			err := r.findIssues(ctx, owner, repo, d, 1, nil, func(_ int, issues []github.Issue) (int, bool) {
+				for _, i := range issues {
+					if i.GetUpdatedAt().Before(since) {
+						continue
+					}
+					if obsErr = r.observeIssue(ctx, owner, repo, i, d, since, fn); obsErr != nil {
+						return 0, false
+					}
+				}
+				return 0, true
+			})
+			if err != nil {
+				return fmt.Errorf("finding issues: %w", classifyAPIError(err))
+			}
+			if obsErr != nil {
+				return obsErr
+			}
+		}
+		return nil
+	}
+
+	for _, e := range cfg.Entries {
+		repos, err := r.repoNames(ctx, e)
+		if err != nil {
+			return err
+		}
+		for _, repo := range repos {
+			if err := observeRepo(e.Owner, repo, e.Directives); err != nil {
+				return fmt.Errorf("%s/%s: %w", e.Owner, repo, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runner) observeIssue(ctx context.Context, owner, repo string, i github.Issue, d Directive, since time.Time, fn func(Observation) error) error {
+	events, err := listTimeline(ctx, r.Client, owner, repo, i.GetNumber())
+	if err != nil {
+		return fmt.Errorf("timeline of issue %d: %w", i.GetNumber(), err)
+	}
+	matches := r.matches(i, d)
+	for _, ev := range events {
+		if !observedEvents[ev.Event] || ev.CreatedAt.Before(since) {
+			continue
+		}
+		o := Observation{
+			Owner:     owner,
+			Repo:      repo,
+			Issue:     i.GetNumber(),
+			Directive: d.Name,
+			Kind:      ev.Event,
+			Actor:     ev.Actor.GetLogin(),
+			At:        ev.CreatedAt,
+			Label:     ev.Label.GetName(),
+			Matches:   matches,
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// searchLimit is the most results the search API returns for a query.
+const searchLimit = 1000
+
+// orgSearched returns true if the directive is one that an entry with
+// OrgSearch runs only in the repos where its query has matches.
+func (e Entry) orgSearched(d Directive) bool {
+	return e.OrgSearch && len(e.Repos) == 0 && d.Query != "" && d.Resurrect == nil && d.UnlockAfterDays == 0
+}
+
+// searchedIn returns false if an org search found the directive to have
+// no matches in the repo.
+func (d Directive) searchedIn(repo string) bool {
+	return d.orgRepos == nil || d.orgRepos[repo]
+}
+
+// orgSearch finds the repos with matches for each of the entry's org
+// searched directives, with one search over the owner. It returns the
+// entry with those directives limited to their repos, and the repos still
+// worth processing.
+func (r *Runner) orgSearch(ctx context.Context, e Entry, repos []string) (Entry, []string, error) {
+	if !e.OrgSearch || len(e.Repos) > 0 {
+		return e, repos, nil
+	}
+
+	e.Directives = append([]Directive(nil), e.Directives...)
+	wanted := make(map[string]bool)
+	all := len(e.Discussions) > 0
+	for i := range e.Directives {
+		d := &e.Directives[i]
+		if !e.orgSearched(*d) {
+			all = true
+			continue
+		}
+		found, err := r.orgMatches(ctx, e.Owner, *d)
+		if err != nil {
+			return e, nil, fmt.Errorf("searching %s for directive %s: %w", e.Owner, d.Name, classifyAPIError(err))
+		}
+		if found == nil {
+			all = true
+			continue
+		}
+		infof("Directive %s has matches in %d repos of %s", d.Name, len(found), e.Owner)
+		d.orgRepos = found
+		for repo := range found {
+			wanted[repo] = true
+		}
+	}
+	if all {
+		return e, repos, nil
+	}
+
+	var res []string
+	for _, repo := range repos {
+		if wanted[repo] {
+			res = append(res, repo)
+		}
+	}
+	return e, res, nil
+}
+
+// orgMatches returns the repos of the owner with issues matching the
+// directive's query, or nil if that can't be told. The search API returns
+// at most a thousand results per query, so larger result sets are
+// partitioned by creation time.
+func (r *Runner) orgMatches(ctx context.Context, owner string, d Directive) (map[string]bool, error) {
+	base := r.searchQuery(d, "org:"+owner)
+	opts := github.SearchOptions{
+		Sort:        "created",
+		Order:       "asc",
+		ListOptions: github.ListOptions{PerPage: perPage},
+	}
+
+	found := make(map[string]bool)
+	from := ""
+	for {
+		query := base
+		if from != "" {
+			query += " created:>=" + from
+		}
+		n := 0
+		var last time.Time
+		opts.Page = 1
+		for {
+			res, resp, err := r.Client.Search.Issues(ctx, query, &opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, i := range res.Issues {
+				found[path.Base(i.GetRepositoryURL())] = true
+				last = i.GetCreatedAt()
+			}
+			n += len(res.Issues)
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+		if n < searchLimit {
+			return found, nil
+		}
+		next := last.UTC().Format(time.RFC3339)
+		if next == from {
+			warnf("Query %q has more than %d matches created at %s; searching each repo of %s instead", d.Query, searchLimit, from, owner)
+			return nil, nil
+		}
+		from = next
+	}
+}
@@ -0,0 +1,74 @@
+package freeze
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PacingTransport spaces out requests by a delay that adapts to how GitHub
+// is doing: it doubles when requests fail, are rate limited or take longer
+// than SlowLatency, and shrinks by a tenth for each healthy response,
+// staying between MinDelay and MaxDelay.
+type PacingTransport struct {
+	Transport   http.RoundTripper
+	MinDelay    time.Duration
+	MaxDelay    time.Duration
+	SlowLatency time.Duration
+
+	mut   sync.Mutex
+	delay time.Duration
+	next  time.Time // earliest time for the next request to start
+}
+
+func (t *PacingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := sleepCtx(req, t.reserve()); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := t.Transport.RoundTrip(req)
+	healthy := err == nil && resp.StatusCode < 500 && time.Since(start) < t.SlowLatency
+	if healthy {
+		_, limited := rateLimitWait(resp)
+		healthy = !limited
+	}
+	t.adapt(healthy)
+	return resp, err
+}
+
+// reserve returns how long to wait before sending a request, reserving the
+// slot after it for the next one.
+func (t *PacingTransport) reserve() time.Duration {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.delay < t.MinDelay {
+		t.delay = t.MinDelay
+	}
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	wait := t.next.Sub(now)
+	t.next = t.next.Add(t.delay)
+	return wait
+}
+
+func (t *PacingTransport) adapt(healthy bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	prev := t.delay
+	if healthy {
+		t.delay -= t.delay / 10
+		if t.delay < t.MinDelay {
+			t.delay = t.MinDelay
+		}
+		return
+	}
+	t.delay = 2*t.delay + 100*time.Millisecond
+	if t.delay > t.MaxDelay {
+		t.delay = t.MaxDelay
+	}
+	if t.delay != prev {
+		warnf("GitHub is slow or failing; pacing requests %v apart", t.delay.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,285 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// A Plan is the list of actions that applying a configuration calls for,
+// in the order they should be performed.
+type Plan struct {
+	Actions []Action
+}
+
+// An Action is a single change to an issue.
+type Action struct {
+	Owner     string
+	Repo      string
+	Issue     int
+	Directive string
+	Kind      string
+	// IssueUpdatedAt is when the issue was last updated at the time the
+	// action was decided.
+	IssueUpdatedAt time.Time
+	// Label is set for label and unlabel actions.
+	Label string `json:",omitempty"`
+	// Comment is set for comment actions.
+	Comment string `json:",omitempty"`
+	// Reaction, if set on a comment action, is added by us to the new
+	// comment.
+	Reaction string `json:",omitempty"`
+	// CommentID is the ID of the comment, once it's been created.
+	CommentID int64 `json:",omitempty"`
+	// LockReason, if set on a lock action, is shown on the locked issue.
+	LockReason string `json:",omitempty"`
+	// TransferTo is the "owner/repo" to move the issue to, for transfer
+	// actions.
+	TransferTo string `json:",omitempty"`
+	// Category is the discussion category, and Title and Body the
+	// discussion, for discussion actions. DiscussionURL is set once the
+	// discussion is created.
+	Category      string `json:",omitempty"`
+	Title         string `json:",omitempty"`
+	Body          string `json:",omitempty"`
+	DiscussionURL string `json:",omitempty"`
+	// Assignees are the users to add or remove, for assign and unassign
+	// actions.
+	Assignees []string `json:",omitempty"`
+	// Milestone is the title of the milestone to set, for milestone
+	// actions.
+	Milestone string `json:",omitempty"`
+	// IssueType is the issue type to set, for type actions.
+	IssueType string `json:",omitempty"`
+	// CommentNodeID is the GraphQL ID of the comment to mark as the
+	// answer, for answer actions.
+	CommentNodeID string `json:",omitempty"`
+	// IssueNodeID is the GraphQL ID of the issue, or the discussion for
+	// discussion actions.
+	IssueNodeID string `json:",omitempty"`
+	// Before and After are the issue as we expect it before and after the
+	// action, so that a plan shows its full effect.
+	Before *IssueSnapshot `json:",omitempty"`
+	After  *IssueSnapshot `json:",omitempty"`
+
+	// CommandID, if set, is the last slash command comment handled by
+	// the action, to record in the State once it's performed.
+	CommandID int64 `json:",omitempty"`
+	// Summary, if set on a close action, is added to the summaries once
+	// the issue is closed.
+	Summary *SummaryLine `json:",omitempty"`
+}
+
+// An IssueSnapshot is the part of an issue that actions change.
+type IssueSnapshot struct {
+	State     string
+	Locked    bool
+	Labels    []string `json:",omitempty"`
+	Assignees []string `json:",omitempty"`
+	Milestone string   `json:",omitempty"`
+}
+
+func snapshot(i github.Issue) *IssueSnapshot {
+	s := &IssueSnapshot{
+		State:     i.GetState(),
+		Locked:    i.GetLocked(),
+		Milestone: i.GetMilestone().GetTitle(),
+	}
+	for _, l := range i.Labels {
+		s.Labels = append(s.Labels, l.GetName())
+	}
+	for _, u := range i.Assignees {
+		s.Assignees = append(s.Assignees, u.GetLogin())
+	}
+	return s
+}
+
+// addSnapshots sets Before and After of the actions on the issue, applying
+// them in order to a copy of it.
+func addSnapshots(actions []Action, i github.Issue, now time.Time) {
+	for n := range actions {
+		actions[n].Before = snapshot(i)
+		applyLocally(&i, actions[n:n+1], now)
+		actions[n].After = snapshot(i)
+	}
+}
+
+// Action kinds.
+const (
+	ActionLabel    = "label"
+	ActionComment  = "comment"
+	ActionClose    = "close"
+	ActionLock     = "lock"
+	ActionTransfer = "transfer"
+	ActionReopen   = "reopen"
+	ActionUnlock   = "unlock"
+	ActionUnlabel  = "unlabel"
+	// ActionDiscussion creates a discussion from the issue and comments
+	// on the issue with a link to it.
+	ActionDiscussion = "discussion"
+	// ActionDraft converts a pull request to a draft.
+	ActionDraft = "draft"
+	// ActionType sets the organization issue type of the issue.
+	ActionType     = "type"
+	ActionAssign   = "assign"
+	ActionUnassign = "unassign"
+	// ActionAnswer, ActionDiscussionComment and ActionDiscussionLock act
+	// on discussions rather than issues.
+	ActionAnswer            = "answer"
+	ActionDiscussionComment = "discussion-comment"
+	ActionDiscussionLock    = "discussion-lock"
+	ActionPin               = "pin"
+	ActionUnpin             = "unpin"
+	// ActionMilestone moves the issue to an open milestone, by title.
+	ActionMilestone = "milestone"
+)
+
+func validActionKind(kind string) bool {
+	switch kind {
+	case ActionLabel, ActionComment, ActionClose, ActionLock, ActionTransfer, ActionReopen, ActionUnlock, ActionUnlabel, ActionDiscussion, ActionType, ActionDraft, ActionMilestone, ActionAssign, ActionUnassign, ActionPin, ActionUnpin, ActionAnswer, ActionDiscussionComment, ActionDiscussionLock:
+		return true
+	default:
+		return false
+	}
+}
+
+func (a Action) String() string {
+	switch a.Kind {
+	case ActionLabel, ActionUnlabel:
+		return fmt.Sprintf("%s %s/%s#%d %q (%s)", a.Kind, a.Owner, a.Repo, a.Issue, a.Label, a.Directive)
+	case ActionTransfer:
+		return fmt.Sprintf("%s %s/%s#%d to %s (%s)", a.Kind, a.Owner, a.Repo, a.Issue, a.TransferTo, a.Directive)
+	case ActionType:
+		return fmt.Sprintf("%s %s/%s#%d %q (%s)", a.Kind, a.Owner, a.Repo, a.Issue, a.IssueType, a.Directive)
+	case ActionAssign, ActionUnassign:
+		return fmt.Sprintf("%s %s/%s#%d %s (%s)", a.Kind, a.Owner, a.Repo, a.Issue, strings.Join(a.Assignees, ", "), a.Directive)
+	case ActionMilestone:
+		return fmt.Sprintf("%s %s/%s#%d %q (%s)", a.Kind, a.Owner, a.Repo, a.Issue, a.Milestone, a.Directive)
+	case ActionDiscussion:
+		return fmt.Sprintf("%s %s/%s#%d in %q (%s)", a.Kind, a.Owner, a.Repo, a.Issue, a.Category, a.Directive)
+	default:
+		return fmt.Sprintf("%s %s/%s#%d (%s)", a.Kind, a.Owner, a.Repo, a.Issue, a.Directive)
+	}
+}
+
+// Evaluate returns the actions that applying the configuration would
+// perform, without performing any of them. The state is left as it was;
+// checkpoints, seen commands and the like are only recorded in a copy.
+func (r *Runner) Evaluate(ctx context.Context, cfg Config) (Plan, error) {
+	st := r.State
+	if st != nil {
+		r.State = st.ReadOnly()
+		defer func() { r.State = st }()
+	}
+	var rec RecordingSink
+	err := r.walk(ctx, cfg, &rec)
+	return Plan{Actions: rec.Actions}, err
+}
+
+// Execute passes the actions of the plan to the runner's sink, in order,
+// subject to the configuration's allowed actions as in a run. As in a run,
+// the remaining actions on an issue are skipped after a failed one, and
+// the state is saved afterwards. The summary comments of the plan are made
+// anew from the issues actually closed.
+//
+// The configuration is the one in force when executing, not necessarily
+// the one the plan was made from; only its AllowedActions are used. They
+// are the deployment's guardrail, so they bound a plan made elsewhere or
+// before they were narrowed just as they bound a run. A zero Config
+// allows everything in the plan.
+func (r *Runner) Execute(ctx context.Context, cfg Config, plan Plan) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	var sink ActionSink = &summarySink{summaries: &r.summaries, next: r.sink()}
+	if len(cfg.AllowedActions) > 0 {
+		sink = &allowedSink{cfg: cfg, next: sink}
+	}
+	r.summaries.reset()
+	r.report.reset()
+	defer r.report.done()
+	failures := &failureSink{next: &countingSink{prog: newProgress(0), report: &r.report, next: sink}}
+
+	// Filter the actions on each issue together, as decide does.
+	actions := plan.Actions
+	for len(actions) > 0 {
+		n := 1
+		for n < len(actions) && actions[n].Owner == actions[0].Owner && actions[n].Repo == actions[0].Repo && actions[n].Issue == actions[0].Issue {
+			n++
+		}
+		for _, a := range cfg.allowedActions(actions[:n]) {
+			if a.Directive == summaryDirective {
+				continue
+			}
+			if err := failures.Act(ctx, a); err != nil {
+				r.State.Save()
+				return err
+			}
+		}
+		actions = actions[n:]
+	}
+	if err := r.summaries.flush(ctx, failures, r.Clock.Now().Format("2006-01-02")); err != nil {
+		r.State.Save()
+		return err
+	}
+	if err := r.State.Save(); err != nil {
+		return err
+	}
+	if failures.count() > 0 {
+		return &PartialFailureError{FailedActions: failures.count()}
+	}
+	return nil
+}
+
+// DropStale returns the plan without the actions for issues and
+// discussions that have been updated since the plan was made. Summary
+// comments are kept, as Execute makes them anew from the issues it closes.
+func (r *Runner) DropStale(ctx context.Context, plan Plan) (Plan, error) {
+	type issueKey struct {
+		owner, repo string
+		number      int
+		discussion  bool
+	}
+	key := func(a Action) issueKey {
+		return issueKey{a.Owner, a.Repo, a.Issue, onDiscussion(a.Kind)}
+	}
+	stale := make(map[issueKey]bool)
+	for _, a := range plan.Actions {
+		if a.Directive == summaryDirective {
+			continue
+		}
+		k := key(a)
+		if _, ok := stale[k]; ok {
+			continue
+		}
+		var updated time.Time
+		if k.discussion {
+			var err error
+			updated, err = r.discussionUpdatedAt(ctx, a.Owner, a.Repo, a.Issue)
+			if err != nil {
+				return Plan{}, fmt.Errorf("checking discussion %s/%s#%d: %w", a.Owner, a.Repo, a.Issue, classifyAPIError(err))
+			}
+		} else {
+			i, _, err := r.Client.Issues.Get(ctx, a.Owner, a.Repo, a.Issue)
+			if err != nil {
+				return Plan{}, fmt.Errorf("checking %s/%s#%d: %w", a.Owner, a.Repo, a.Issue, classifyAPIError(err))
+			}
+			updated = i.GetUpdatedAt()
+		}
+		stale[k] = !updated.Equal(a.IssueUpdatedAt)
+		if stale[k] {
+			infof("Skipping actions on %s/%s#%d; changed since planning", a.Owner, a.Repo, a.Issue)
+		}
+	}
+
+	var res Plan
+	for _, a := range plan.Actions {
+		if a.Directive == summaryDirective || !stale[key(a)] {
+			res.Actions = append(res.Actions, a)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,167 @@
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// A plugin is a WebAssembly module deciding the actions for issues. The
+// module must export its memory and the functions
+//
+//	alloc(size i32) i32
+//	decide(ptr i32, len i32) i64
+//
+// decide is passed the JSON encoded issue, as placed in memory returned by
+// alloc, and returns the location of its JSON encoded list of actions as
+// ptr<<32 | len. If the module exports free(ptr i32), it's called for both
+// buffers once they've been used.
+type plugin struct {
+	path   string
+	mut    sync.Mutex // the module instance is single threaded
+	mod    api.Module
+	alloc  api.Function
+	decide api.Function
+	free   api.Function
+}
+
+// pluginIssue is what a plugin gets to see of the issue.
+type pluginIssue struct {
+	Number           int      `json:"number"`
+	Title            string   `json:"title"`
+	Body             string   `json:"body"`
+	State            string   `json:"state"`
+	Author           string   `json:"author"`
+	Comments         int      `json:"comments"`
+	IsPR             bool     `json:"is_pr"`
+	Labels           []string `json:"labels"`
+	DaysSinceCreated int      `json:"days_since_created"`
+	DaysSinceUpdated int      `json:"days_since_updated"`
+	DaysSinceClosed  int      `json:"days_since_closed"`
+}
+
+type pluginAction struct {
+	Kind       string `json:"kind"`
+	Label      string `json:"label"`
+	Comment    string `json:"comment"`
+	TransferTo string `json:"transfer_to"`
+	LockReason string `json:"lock_reason"`
+}
+
+func loadPlugin(ctx context.Context, path string) (*plugin, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := wazero.NewRuntime(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, rt)
+	mod, err := rt.InstantiateWithConfig(ctx, bs, wazero.NewModuleConfig().WithName(path).WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &plugin{
+		path:   path,
+		mod:    mod,
+		alloc:  mod.ExportedFunction("alloc"),
+		decide: mod.ExportedFunction("decide"),
+		free:   mod.ExportedFunction("free"),
+	}
+	if p.alloc == nil || p.decide == nil || mod.Memory() == nil {
+		return nil, fmt.Errorf("%s: module must export memory, alloc and decide", path)
+	}
+	return p, nil
+}
+
+func (p *plugin) call(ctx context.Context, input []byte) ([]byte, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	res, err := p.alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	inPtr := uint32(res[0])
+	if !p.mod.Memory().Write(inPtr, input) {
+		return nil, fmt.Errorf("alloc returned out of range pointer %d", inPtr)
+	}
+
+	res, err = p.decide.Call(ctx, uint64(inPtr), uint64(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	outPtr, outLen := uint32(res[0]>>32), uint32(res[0])
+	out, ok := p.mod.Memory().Read(outPtr, outLen)
+	if !ok {
+		return nil, fmt.Errorf("decide returned out of range result %d+%d", outPtr, outLen)
+	}
+	out = append([]byte(nil), out...)
+
+	if p.free != nil {
+		if _, err := p.free.Call(ctx, uint64(inPtr)); err != nil {
+			return nil, err
+		}
+		if _, err := p.free.Call(ctx, uint64(outPtr)); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// pluginActions asks the directive's plugin what to do with the issue.
+func (d Directive) pluginActions(ctx context.Context, base Action, now time.Time, i github.Issue) ([]Action, error) {
+	labels := make([]string, len(i.Labels))
+	for n, l := range i.Labels {
+		labels[n] = l.GetName()
+	}
+	input, err := json.Marshal(pluginIssue{
+		Number:           i.GetNumber(),
+		Title:            i.GetTitle(),
+		Body:             i.GetBody(),
+		State:            i.GetState(),
+		Author:           i.GetUser().GetLogin(),
+		Comments:         i.GetComments(),
+		IsPR:             i.IsPullRequest(),
+		Labels:           labels,
+		DaysSinceCreated: d.daysSince(now, i.GetCreatedAt()),
+		DaysSinceUpdated: d.daysSince(now, i.GetUpdatedAt()),
+		DaysSinceClosed:  d.daysSince(now, i.GetClosedAt()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := d.plugin.call(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Plugin, err)
+	}
+
+	var pas []pluginAction
+	if err := json.Unmarshal(out, &pas); err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Plugin, err)
+	}
+
+	actions := make([]Action, len(pas))
+	for n, pa := range pas {
+		a := base
+		a.Kind = pa.Kind
+		a.Label = pa.Label
+		a.Comment = pa.Comment
+		a.TransferTo = pa.TransferTo
+		a.LockReason = pa.LockReason
+		if err := checkDecidedAction(&a); err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Plugin, err)
+		}
+		actions[n] = a
+	}
+	return actions, nil
+}
@@ -0,0 +1,99 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// actionPool performs groups of actions using a number of workers. The
+// actions within a group, typically those for one issue, are performed in
+// order by the same worker. A panic in a worker becomes its error.
+type actionPool struct {
+	sink  ActionSink
+	queue chan []Action
+	wg    sync.WaitGroup
+	// pending counts the groups queued and not yet performed.
+	pending sync.WaitGroup
+	closed  sync.Once
+
+	mut sync.Mutex
+	err error
+}
+
+func newActionPool(ctx context.Context, sink ActionSink, workers int) *actionPool {
+	p := &actionPool{sink: sink, queue: make(chan []Action)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work(ctx)
+	}
+	return p
+}
+
+func (p *actionPool) work(ctx context.Context) {
+	defer p.wg.Done()
+	for actions := range p.queue {
+		if p.failed() == nil {
+			if err := p.act(ctx, actions); err != nil {
+				p.mut.Lock()
+				if p.err == nil {
+					p.err = err
+				}
+				p.mut.Unlock()
+			}
+		}
+		// Otherwise drain the queue without acting.
+		p.pending.Done()
+	}
+}
+
+func (p *actionPool) act(ctx context.Context, actions []Action) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			warnf("Panic performing actions on %s/%s#%d: %v\n%s", actions[0].Owner, actions[0].Repo, actions[0].Issue, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	for _, a := range actions {
+		if err := p.sink.Act(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *actionPool) failed() error {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	return p.err
+}
+
+// submit queues the actions, returning the first error from the workers
+// so far, if any.
+func (p *actionPool) submit(actions []Action) error {
+	if err := p.failed(); err != nil {
+		return err
+	}
+	if len(actions) > 0 {
+		p.pending.Add(1)
+		p.queue <- actions
+	}
+	return nil
+}
+
+// flush waits for the actions queued so far to be performed and returns
+// the first error from the workers, if any.
+func (p *actionPool) flush() error {
+	p.pending.Wait()
+	return p.failed()
+}
+
+// wait waits for all queued actions to be performed and returns the first
+// error, if any. The pool can't be used afterwards, but wait may be called
+// again.
+func (p *actionPool) wait() error {
+	p.closed.Do(func() { close(p.queue) })
+	p.wg.Wait()
+	return p.failed()
+}
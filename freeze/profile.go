@@ -0,0 +1,125 @@
+package freeze
+
+import (
+	"fmt"
+)
+
+// A Profile bounds what any configuration may do in a deployment, whatever
+// its directives ask for.
+type Profile struct {
+	Name string
+	// Actions are the action kinds allowed; nil allows all.
+	Actions []string
+	// MinDays is the least DaysNotUpdated, DaysClosed and DaysLocked
+	// allowed; lower thresholds are raised to it.
+	MinDays int
+}
+
+var profiles = []Profile{
+	{
+		// Lock only, never close.
+		Name:    "conservative",
+		Actions: []string{ActionLabel, ActionUnlabel, ActionComment, ActionLock, ActionUnlock},
+		MinDays: 180,
+	},
+	{
+		// Nothing that moves issues elsewhere.
+		Name:    "standard",
+		Actions: []string{ActionLabel, ActionUnlabel, ActionComment, ActionClose, ActionLock, ActionReopen, ActionUnlock, ActionType, ActionDraft, ActionMilestone, ActionAssign, ActionUnassign, ActionPin, ActionUnpin},
+		MinDays: 30,
+	},
+	{
+		Name: "aggressive",
+	},
+}
+
+// LookupProfile returns the profile with the given name: "conservative",
+// "standard" or "aggressive". The empty name is the same as aggressive,
+// i.e. no limits.
+func LookupProfile(name string) (Profile, error) {
+	if name == "" {
+		name = "aggressive"
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Profile{}, &ConfigError{fmt.Errorf("unknown profile %q", name)}
+}
+
+func (p Profile) allows(kind string) bool {
+	if p.Actions == nil {
+		return true
+	}
+	for _, k := range p.Actions {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply limits the configuration to the profile, narrowing the allowed
+// actions and raising thresholds below the minimum.
+func (p Profile) Apply(cfg *Config) error {
+	if p.Actions != nil {
+		if len(cfg.AllowedActions) == 0 {
+			cfg.AllowedActions = p.Actions
+		} else {
+			var allowed []string
+			for _, kind := range cfg.AllowedActions {
+				if p.allows(kind) {
+					allowed = append(allowed, kind)
+				}
+			}
+			if len(allowed) == 0 {
+				return &ConfigError{fmt.Errorf("profile %s allows none of the allowed actions", p.Name)}
+			}
+			cfg.AllowedActions = allowed
+		}
+	}
+
+	if p.MinDays == 0 {
+		return nil
+	}
+	raise := func(e *Entry) {
+		for i := range e.Directives {
+			d := &e.Directives[i]
+			for _, t := range []struct {
+				name string
+				days *int
+			}{
+				{"daysNotUpdated", &d.DaysNotUpdated},
+				{"daysClosed", &d.DaysClosed},
+				{"daysClosedByHuman", &d.DaysClosedByHuman},
+				{"daysLocked", &d.DaysLocked},
+			} {
+				if *t.days > 0 && *t.days < p.MinDays {
+					infof("Raising %s of %s directive %q from %d to %d, per profile %s", t.name, e.Owner, d.Name, *t.days, p.MinDays, p.Name)
+					*t.days = p.MinDays
+				}
+			}
+		}
+	}
+	for i := range cfg.Entries {
+		raise(&cfg.Entries[i])
+	}
+	for i := range cfg.Campaigns {
+		raise(&cfg.Campaigns[i].Entry)
+	}
+	return nil
+}
+
+// Filter returns the plan without the actions the profile doesn't allow.
+func (p Profile) Filter(plan Plan) Plan {
+	var res Plan
+	for _, a := range plan.Actions {
+		if !p.allows(a.Kind) {
+			infof("Not performing %s; not allowed by profile %s", a, p.Name)
+			continue
+		}
+		res.Actions = append(res.Actions, a)
+	}
+	return res
+}
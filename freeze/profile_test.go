@@ -0,0 +1,78 @@
+package freeze
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLookupProfile(t *testing.T) {
+	for _, name := range []string{"", "conservative", "standard", "aggressive"} {
+		if _, err := LookupProfile(name); err != nil {
+			t.Errorf("LookupProfile(%q): %v", name, err)
+		}
+	}
+	var cerr *ConfigError
+	if _, err := LookupProfile("reckless"); !errors.As(err, &cerr) {
+		t.Errorf("unknown profile gave %v, want a ConfigError", err)
+	}
+}
+
+func TestProfileApply(t *testing.T) {
+	p, _ := LookupProfile("conservative")
+	cfg := Config{
+		AllowedActions: []string{ActionClose, ActionLock, ActionComment},
+		Entries: []Entry{{
+			Owner: "calmh",
+			Directives: []Directive{
+				{Name: "stale", DaysNotUpdated: 30, DaysClosed: 0},
+				{Name: "lock", DaysClosed: 365, DaysLocked: 7},
+			},
+		}},
+		Campaigns: []Campaign{{
+			Name:  "cleanup",
+			Entry: Entry{Directives: []Directive{{DaysClosedByHuman: 1}}},
+		}},
+	}
+	if err := p.Apply(&cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{ActionLock, ActionComment}; !reflect.DeepEqual(cfg.AllowedActions, want) {
+		t.Errorf("got allowed actions %v, want %v", cfg.AllowedActions, want)
+	}
+	ds := cfg.Entries[0].Directives
+	if ds[0].DaysNotUpdated != 180 || ds[0].DaysClosed != 0 {
+		t.Errorf("stale directive: got %d/%d days, want 180/0", ds[0].DaysNotUpdated, ds[0].DaysClosed)
+	}
+	if ds[1].DaysClosed != 365 || ds[1].DaysLocked != 180 {
+		t.Errorf("lock directive: got %d/%d days, want 365/180", ds[1].DaysClosed, ds[1].DaysLocked)
+	}
+	if got := cfg.Campaigns[0].Directives[0].DaysClosedByHuman; got != 180 {
+		t.Errorf("campaign directive: got %d days, want 180", got)
+	}
+}
+
+func TestProfileApplyAllowedActions(t *testing.T) {
+	p, _ := LookupProfile("conservative")
+
+	var cfg Config
+	if err := p.Apply(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.AllowedActions, p.Actions) {
+		t.Errorf("got allowed actions %v, want the profile's %v", cfg.AllowedActions, p.Actions)
+	}
+
+	cfg = Config{AllowedActions: []string{ActionClose, ActionTransfer}}
+	var cerr *ConfigError
+	if err := p.Apply(&cfg); !errors.As(err, &cerr) {
+		t.Errorf("no allowed actions left gave %v, want a ConfigError", err)
+	}
+
+	cfg = Config{AllowedActions: []string{ActionTransfer}}
+	p, _ = LookupProfile("aggressive")
+	if err := p.Apply(&cfg); err != nil || !reflect.DeepEqual(cfg.AllowedActions, []string{ActionTransfer}) {
+		t.Errorf("aggressive profile changed allowed actions to %v, %v", cfg.AllowedActions, err)
+	}
+}
@@ -0,0 +1,105 @@
+package freeze
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// progress keeps track of how far a run has come, for the benefit of
+// operators watching long runs.
+type progress struct {
+	mut        sync.Mutex
+	started    time.Time
+	repos      int
+	reposDone  int
+	repo       string
+	directive  int
+	directives int
+	page       int
+	actions    int
+}
+
+func newProgress(repos int) *progress {
+	return &progress{started: time.Now(), repos: repos}
+}
+
+func (p *progress) startRepo(owner, repo string, directives int) {
+	p.mut.Lock()
+	p.repo = owner + "/" + repo
+	p.directive, p.directives, p.page = 0, directives, 0
+	p.mut.Unlock()
+}
+
+func (p *progress) doneRepo() {
+	p.mut.Lock()
+	p.reposDone++
+	p.mut.Unlock()
+}
+
+func (p *progress) startDirective(idx int) {
+	p.mut.Lock()
+	p.directive, p.page = idx, 0
+	p.mut.Unlock()
+}
+
+func (p *progress) setPage(page int) {
+	p.mut.Lock()
+	p.page = page
+	p.mut.Unlock()
+}
+
+func (p *progress) addAction() {
+	p.mut.Lock()
+	p.actions++
+	p.mut.Unlock()
+}
+
+func (p *progress) log() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	eta := "unknown"
+	if p.reposDone > 0 {
+		elapsed := time.Since(p.started)
+		left := elapsed / time.Duration(p.reposDone) * time.Duration(p.repos-p.reposDone)
+		eta = left.Round(time.Minute).String()
+	}
+	infof("Progress: repo %d/%d (%s), directive %d/%d, page %d, %d actions so far, ETA %s",
+		p.reposDone+1, p.repos, p.repo, p.directive+1, p.directives, p.page, p.actions, eta)
+}
+
+// report logs the progress at the given interval until the returned
+// function is called.
+func (p *progress) report(interval time.Duration) func() {
+	stop := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.log()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
+
+// countingSink counts the actions passing through it.
+type countingSink struct {
+	prog   *progress
+	report *report
+	next   ActionSink
+}
+
+func (s *countingSink) Act(ctx context.Context, a Action) error {
+	if err := s.next.Act(ctx, a); err != nil {
+		return err
+	}
+	s.prog.addAction()
+	s.report.acted(a)
+	return nil
+}
@@ -0,0 +1,130 @@
+package freeze
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// searchQualifiers are the qualifiers GitHub issue search understands.
+var searchQualifiers = map[string]bool{
+	"archived": true, "assignee": true, "author": true, "base": true,
+	"closed": true, "commenter": true, "comments": true, "created": true,
+	"draft": true, "head": true, "in": true, "interactions": true,
+	"involves": true, "is": true, "label": true, "language": true,
+	"linked": true, "mentions": true, "merged": true, "milestone": true,
+	"no": true, "org": true, "project": true, "reactions": true,
+	"reason": true, "repo": true, "review": true, "review-requested": true,
+	"reviewed-by": true, "sort": true, "state": true, "status": true,
+	"team": true, "team-review-requested": true, "type": true,
+	"updated": true, "user": true, "user-review-requested": true,
+}
+
+// queryTerms splits a search query into terms, keeping quoted strings
+// together. It fails on unbalanced quotes.
+func queryTerms(q string) ([]string, error) {
+	var terms []string
+	var cur strings.Builder
+	quoted := false
+	for _, c := range q {
+		switch {
+		case c == '"':
+			quoted = !quoted
+			cur.WriteRune(c)
+		case c == ' ' && !quoted:
+			if cur.Len() > 0 {
+				terms = append(terms, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	if quoted {
+		return nil, errors.New("unbalanced quotes")
+	}
+	if cur.Len() > 0 {
+		terms = append(terms, cur.String())
+	}
+	return terms, nil
+}
+
+// lintQuery checks a directive query, returning an error for queries that
+// can't work and logging warnings for ones that probably don't do what was
+// intended.
+func lintQuery(q string) error {
+	terms, err := queryTerms(q)
+	if err != nil {
+		return err
+	}
+	for _, t := range terms {
+		t = strings.TrimPrefix(t, "-")
+		qual, val, ok := strings.Cut(t, ":")
+		if !ok || strings.HasPrefix(qual, `"`) {
+			continue
+		}
+		switch {
+		case !searchQualifiers[strings.ToLower(qual)]:
+			warnf("Warning: query %q: unknown qualifier %q", q, qual)
+		case val == "":
+			warnf("Warning: query %q: qualifier %q without value", q, qual)
+		}
+	}
+	return nil
+}
+
+func (d *Directive) lintQuery() error {
+	if d.Query == "" {
+		return nil
+	}
+	if err := lintQuery(d.Query); err != nil {
+		return fmt.Errorf("query %q: %w", d.Query, err)
+	}
+
+	terms, _ := queryTerms(d.Query)
+	has := make(map[string]bool)
+	for _, t := range terms {
+		has[strings.ToLower(t)] = true
+	}
+	if (has["is:pr"] || has["type:pr"]) && (has["is:issue"] || has["type:issue"]) {
+		warnf("Warning: query %q: matches both issues and pull requests, i.e. nothing", d.Query)
+	}
+	if d.ItemType == itemIssue && (has["is:pr"] || has["type:pr"]) || d.ItemType == itemPR && (has["is:issue"] || has["type:issue"]) {
+		warnf("Warning: query %q: conflicts with itemType %q, i.e. matches nothing", d.Query, d.ItemType)
+	}
+	if (has["is:open"] || has["state:open"]) && (has["is:closed"] || has["state:closed"]) {
+		warnf("Warning: query %q: matches both open and closed, i.e. nothing", d.Query)
+	}
+	if d.State != "" {
+		warnf("Warning: query %q: state %q is ignored for queries; use is:%s in the query", d.Query, d.State, d.State)
+	}
+	return nil
+}
+
+// checkQueryScope rejects queries with qualifiers that conflict with the
+// "repo:owner/repo" qualifier we add for each repo, as those silently
+// match nothing, or the wrong repo.
+func (d *Directive) checkQueryScope(owner string) error {
+	if d.Query == "" {
+		return nil
+	}
+	terms, err := queryTerms(d.Query)
+	if err != nil {
+		return err
+	}
+	for _, t := range terms {
+		qual, val, ok := strings.Cut(strings.TrimPrefix(t, "-"), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(qual) {
+		case "repo":
+			return fmt.Errorf("query %q: repo: qualifiers are not allowed; the repo is added from the config entry", d.Query)
+		case "org", "user":
+			if !strings.EqualFold(val, owner) {
+				return fmt.Errorf("query %q: %s:%s conflicts with the entry owner %s", d.Query, qual, val, owner)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package freeze
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryTerms(t *testing.T) {
+	cases := []struct {
+		q    string
+		want []string
+		err  bool
+	}{
+		{"", nil, false},
+		{"is:open  label:bug ", []string{"is:open", "label:bug"}, false},
+		{`label:"good first issue" -author:bot`, []string{`label:"good first issue"`, "-author:bot"}, false},
+		{`"crash on start" is:issue`, []string{`"crash on start"`, "is:issue"}, false},
+		{`label:"bug`, nil, true},
+	}
+	for _, tc := range cases {
+		got, err := queryTerms(tc.q)
+		if (err != nil) != tc.err || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("queryTerms(%q) = %q, %v; want %q, error %v", tc.q, got, err, tc.want, tc.err)
+		}
+	}
+}
+
+func TestLintQuery(t *testing.T) {
+	// Unknown qualifiers and missing values are only warned about.
+	for _, q := range []string{"is:open label:bug", "frobnicate:yes", "label:", `"a: b"`} {
+		if err := lintQuery(q); err != nil {
+			t.Errorf("lintQuery(%q) = %v", q, err)
+		}
+	}
+	if err := lintQuery(`label:"bug`); err == nil {
+		t.Error("unbalanced quotes accepted")
+	}
+}
+
+func TestCheckQueryScope(t *testing.T) {
+	cases := []struct {
+		q   string
+		err bool
+	}{
+		{"", false},
+		{"is:open label:bug", false},
+		{"org:calmh is:open", false},
+		{"user:CALMH", false},
+		{"org:other", true},
+		{"-user:other", true},
+		{"repo:calmh/freezebot", true},
+		{"REPO:calmh/freezebot", true},
+		{`label:"bug`, true},
+	}
+	for _, tc := range cases {
+		d := Directive{Query: tc.q}
+		if err := d.checkQueryScope("calmh"); (err != nil) != tc.err {
+			t.Errorf("checkQueryScope(%q) = %v, want error %v", tc.q, err, tc.err)
+		}
+	}
+}
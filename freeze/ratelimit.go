@@ -0,0 +1,95 @@
+package freeze
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitTransport waits for the rate limit to reset when it runs out,
+// instead of letting requests fail. Waits longer than MaxWait are not
+// done; the rate limit error is passed on instead.
+type RateLimitTransport struct {
+	Transport http.RoundTripper
+	MaxWait   time.Duration
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for {
+		resp, err := t.Transport.RoundTrip(req)
+		if err != nil {
+			return resp, err
+		}
+
+		wait, limited := rateLimitWait(resp)
+		if wait <= 0 || wait > t.MaxWait {
+			return resp, nil
+		}
+		if !limited {
+			// The request went through but used up the limit. Wait
+			// before handing over the response, so that the client
+			// doesn't refuse the next request on its own.
+			warnf("Rate limit exhausted; waiting %v for it to reset", wait.Round(time.Second))
+			if err := sleepCtx(req, wait); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+			return resp, nil
+		}
+
+		if req.Body != nil && req.GetBody == nil {
+			// Can't resend the request.
+			return resp, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		warnf("Rate limited; waiting %v before retrying", wait.Round(time.Second))
+		if err := sleepCtx(req, wait); err != nil {
+			return nil, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// rateLimitWait returns how long to wait for the rate limit to reset, and
+// whether the request was refused because of it.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	limited := resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests
+
+	// Secondary rate limits say how long to wait.
+	if ra := resp.Header.Get("Retry-After"); ra != "" && limited {
+		if secs, err := strconv.Atoi(ra); err == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	// A second of margin for clock differences.
+	return time.Until(time.Unix(reset, 0)) + time.Second, limited
+}
+
+func sleepCtx(req *http.Request, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,109 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultRedisKey = "freezebot:state"
+
+// redisStore keeps the values in a Redis hash, so that the state can be
+// shared between stateless runners.
+type redisStore struct {
+	client *redis.Client
+	key    string
+	old    []byte
+}
+
+// newRedisStore connects to the Redis server at the URL, e.g.
+// "redis://:password@host:6379/0?key=freezebot:state". The key parameter
+// names the hash holding the values; "freezebot:state" by default.
+func newRedisStore(rawURL string) (*redisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	key := q.Get("key")
+	if key == "" {
+		key = defaultRedisKey
+	}
+	q.Del("key")
+	u.RawQuery = q.Encode()
+
+	opts, err := redis.ParseURL(u.String())
+	if err != nil {
+		return nil, err
+	}
+	s := &redisStore{client: redis.NewClient(opts), key: key}
+
+	// Earlier versions kept the whole state as a string in the key.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	typ, err := s.client.Type(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if typ == "string" {
+		if s.old, err = s.client.Get(ctx, key).Bytes(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *redisStore) legacy() []byte {
+	return s.old
+}
+
+func (s *redisStore) Get(key string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if s.old != nil {
+		return nil, nil
+	}
+	bs, err := s.client.HGet(ctx, s.key, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return bs, err
+}
+
+func (s *redisStore) Put(key string, value []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if s.old != nil {
+		// Replace the old format.
+		if err := s.client.Del(ctx, s.key).Err(); err != nil {
+			return err
+		}
+		s.old = nil
+	}
+	return s.client.HSet(ctx, s.key, key, value).Err()
+}
+
+func (s *redisStore) Scan(prefix string, fn func(key string, value []byte) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if s.old != nil {
+		return nil
+	}
+	values, err := s.client.HGetAll(ctx, s.key).Result()
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		if err := fn(k, []byte(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package freeze
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// RenderPolicy writes the configuration as a Markdown document describing,
+// in plain words, what happens to issues and when, for publishing to the
+// contributors affected by it.
+func RenderPolicy(w io.Writer, cfg Config) {
+	fmt.Fprintf(w, "# Issue policy\n\n")
+	fmt.Fprintf(w, "Issues are maintained automatically by freezebot, as follows.\n")
+	for _, e := range cfg.Entries {
+		renderEntry(w, entryTitle(e), e)
+	}
+	for _, c := range cfg.Campaigns {
+		renderEntry(w, fmt.Sprintf("Campaign %s, %s to %s: %s", c.Name, orAny(c.Start), orAny(c.End), entryTitle(c.Entry)), c.Entry)
+	}
+}
+
+func entryTitle(e Entry) string {
+	if len(e.Repos) == 0 {
+		return "All repositories of " + e.Owner
+	}
+	return e.Owner + "/" + strings.Join(e.Repos, ", "+e.Owner+"/")
+}
+
+func renderEntry(w io.Writer, title string, e Entry) {
+	fmt.Fprintf(w, "\n## %s\n\n", title)
+	for _, d := range e.Directives {
+		fmt.Fprintf(w, "- %s\n", d.policy())
+	}
+}
+
+// policy describes what the directive does, in a sentence or two.
+func (d Directive) policy() string {
+	switch {
+	case d.UnlockAfterDays > 0:
+		return fmt.Sprintf("Issues locked by us are unlocked again after %d %s.", d.UnlockAfterDays, d.dayWords())
+	case d.Resurrect != nil:
+		var interest []string
+		if d.Resurrect.Reactions > 0 {
+			interest = append(interest, fmt.Sprintf("%d reactions", d.Resurrect.Reactions))
+		}
+		if d.Resurrect.Comments > 0 {
+			interest = append(interest, fmt.Sprintf("%d comments", d.Resurrect.Comments))
+		}
+		what := "reopened"
+		if d.Resurrect.Label != "" {
+			what = fmt.Sprintf("labeled %q", d.Resurrect.Label)
+		}
+		return fmt.Sprintf("Issues closed by us that get %s after closing are %s.", strings.Join(interest, " or "), what)
+	}
+
+	subject := d.policySubject()
+	var sentences []string
+	switch {
+	case d.SlashCommands != nil:
+		cmds := strings.Join(d.SlashCommands.Commands, "`, `")
+		who := "Maintainers"
+		if len(d.SlashCommands.Teams) > 0 {
+			who = "Members of " + strings.Join(d.SlashCommands.Teams, ", ")
+		}
+		sentences = append(sentences, fmt.Sprintf("%s can comment `%s` on %s to have freezebot carry out the command.", who, cmds, strings.ToLower(subject)))
+	case d.EOL != nil:
+		var what []string
+		if d.EOL.Label != "" {
+			what = append(what, fmt.Sprintf("labeled %q", d.EOL.Label))
+		}
+		if d.EOL.Close {
+			what = append(what, "closed")
+		}
+		sentences = append(sentences, fmt.Sprintf("%s reporting versions older than %s, which are no longer supported, are %s.", subject, d.EOL.MinVersion, strings.Join(what, " and ")))
+	case d.Script != "", d.Plugin != "":
+		sentences = append(sentences, fmt.Sprintf("%s%s are handled according to custom rules.", subject, d.policyConditions()))
+	case d.WarnComment != "":
+		label := d.Label
+		d.Label = ""
+		sentences = append(sentences, fmt.Sprintf("%s%s get a warning comment and are labeled %q.", subject, d.policyConditions(), label))
+		sentences = append(sentences, fmt.Sprintf("If there is no further activity for %d %s, they are %s; otherwise the label is removed.", d.DaysAfterWarn, d.dayWords(), d.policyActions()))
+		if d.GraceDays > 0 {
+			sentences = append(sentences, fmt.Sprintf("Pull request authors who reply to the warning get %d more %s instead, once.", d.GraceDays, d.dayWords()))
+		}
+	default:
+		sentences = append(sentences, fmt.Sprintf("%s%s are %s.", subject, d.policyConditions(), d.policyActions()))
+	}
+
+	if d.ExemptMembers {
+		sentences = append(sentences, "Issues opened by maintainers are exempt.")
+	}
+	if len(d.ExemptAuthors) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Issues opened by %s are exempt.", strings.Join(d.ExemptAuthors, ", ")))
+	}
+	if len(d.ExemptAssignees) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Issues assigned to %s are exempt.", strings.Join(d.ExemptAssignees, ", ")))
+	}
+	if len(d.ExemptMilestones) > 0 {
+		m := "the open milestones " + strings.Join(d.ExemptMilestones, ", ")
+		for _, name := range d.ExemptMilestones {
+			if name == "any" {
+				m = "open milestones"
+			}
+		}
+		sentences = append(sentences, fmt.Sprintf("Issues in %s are exempt.", m))
+	}
+	if len(d.ExemptIfCommentedByTeam) > 0 {
+		sentences = append(sentences, fmt.Sprintf("Issues with recent comments from %s are exempt.", strings.Join(d.ExemptIfCommentedByTeam, ", ")))
+	}
+	if d.ExemptIfReferencedDays > 0 {
+		sentences = append(sentences, fmt.Sprintf("Issues referenced from open issues or pull requests in other repositories in the last %d days are exempt.", d.ExemptIfReferencedDays))
+	}
+	if len(d.NoCloseFor) > 0 && d.Close {
+		sentences = append(sentences, fmt.Sprintf("Issues opened by %s are not closed.", strings.Join(d.NoCloseFor, " or ")))
+	}
+	if d.TrackedTasks != "" && d.Close {
+		sentences = append(sentences, "Issues that are open tasks of another issue are not closed.")
+	}
+	if d.HonorCommands {
+		sentences = append(sentences, "Maintainers can exempt an issue by commenting `/freezebot ignore`, or postpone this by commenting `/freezebot snooze 30d`.")
+	}
+	return strings.Join(sentences, " ")
+}
+
+func (d Directive) policySubject() string {
+	noun, Noun := "issues", "Issues"
+	if d.ItemType == itemPR {
+		noun, Noun = "pull requests", "Pull requests"
+	}
+	if d.Query != "" {
+		return fmt.Sprintf("%s matching `%s`", Noun, d.Query)
+	}
+	switch d.State {
+	case "closed":
+		if d.DaysClosed > 0 || d.DaysClosedByHuman > 0 {
+			// Said by the conditions.
+			return Noun
+		}
+		return "Closed " + noun
+	case "all":
+		return Noun
+	default:
+		return "Open " + noun
+	}
+}
+
+func (d Directive) policyConditions() string {
+	var conds []string
+	switch {
+	case d.DaysClosedByHuman > 0 && d.DaysClosed > 0:
+		conds = append(conds, fmt.Sprintf("closed for %s %d %s by a bot, or %d %s by a person", d.thresholdWords(), d.DaysClosed, d.dayWords(), d.DaysClosedByHuman, d.dayWords()))
+	case d.DaysClosedByHuman > 0:
+		conds = append(conds, fmt.Sprintf("closed by a bot, or by a person %s %d %s ago", d.thresholdWords(), d.DaysClosedByHuman, d.dayWords()))
+	case d.DaysClosed > 0:
+		conds = append(conds, fmt.Sprintf("closed for %s %d %s", d.thresholdWords(), d.DaysClosed, d.dayWords()))
+	}
+	if d.DaysNotUpdated > 0 {
+		what := "without activity"
+		if d.Activity == activityHuman {
+			what = "without comments from people"
+		}
+		if d.NeedsInfo != "" {
+			what = fmt.Sprintf("labeled %q and without a reply from the author", d.NeedsInfo)
+		}
+		conds = append(conds, fmt.Sprintf("%s for %s %d %s", what, d.thresholdWords(), d.DaysNotUpdated, d.dayWords()))
+	}
+	if d.DaysLocked > 0 {
+		conds = append(conds, fmt.Sprintf("locked for %s %d %s", d.thresholdWords(), d.DaysLocked, d.dayWords()))
+	}
+	if len(d.Patterns) > 0 {
+		conds = append(conds, "that look like they belong elsewhere")
+	}
+	if d.When != "" {
+		conds = append(conds, fmt.Sprintf("where `%s`", d.When))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return " " + strings.Join(conds, " and ")
+}
+
+func (d Directive) policyActions() string {
+	var what []string
+	if d.Label != "" {
+		what = append(what, fmt.Sprintf("labeled %q", d.Label))
+	}
+	if d.IssueType != "" {
+		what = append(what, fmt.Sprintf("given the type %q if they have none", d.IssueType))
+	} else if len(d.IssueTypeByLabel) > 0 {
+		what = append(what, "given an issue type by label if they have none")
+	}
+	for _, l := range d.RemoveLabels {
+		what = append(what, fmt.Sprintf("unlabeled %q", l))
+	}
+	if len(d.AgeLabels) > 0 {
+		var rungs []string
+		for _, al := range d.AgeLabels {
+			rungs = append(rungs, fmt.Sprintf("%q after %d %s", al.Label, al.Days, d.dayWords()))
+		}
+		what = append(what, "labeled by age, with "+strings.Join(rungs, ", "))
+	}
+	if d.Unassign {
+		what = append(what, "unassigned")
+	}
+	if len(d.Assign) > 0 {
+		what = append(what, "assigned to "+strings.Join(d.Assign, " or ")+" for triage")
+	}
+	if d.SetMilestone != "" {
+		what = append(what, fmt.Sprintf("moved to the %q milestone", d.SetMilestone))
+	}
+	if d.Close {
+		if d.CloseComment != "" || len(d.CloseCommentByAssociation) > 0 {
+			what = append(what, "closed with a comment explaining why")
+		} else {
+			what = append(what, "closed")
+		}
+	}
+	if d.Unlock {
+		what = append(what, "unlocked")
+	}
+	if d.Draft {
+		what = append(what, "converted to drafts")
+	}
+	if d.Pin {
+		what = append(what, "pinned")
+	}
+	if d.Unpin {
+		what = append(what, "unpinned")
+	}
+	if d.Lock {
+		what = append(what, "locked")
+	}
+	if d.ConvertToDiscussion != "" {
+		what = append(what, fmt.Sprintf("moved to a discussion in %q", d.ConvertToDiscussion))
+	}
+	if d.TransferTo != "" {
+		if d.TransferComment != "" {
+			what = append(what, "moved to "+d.TransferTo+" with a comment explaining why")
+		} else {
+			what = append(what, "moved to "+d.TransferTo)
+		}
+	}
+	if len(what) == 0 {
+		return "left as they are"
+	}
+	if len(what) == 1 {
+		return what[0]
+	}
+	return strings.Join(what[:len(what)-1], ", ") + " and " + what[len(what)-1]
+}
@@ -0,0 +1,133 @@
+package freeze
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// report counts the issues matched and the actions taken per repo and
+// directive during a run, for the summary at the end of it.
+type report struct {
+	mut     sync.Mutex
+	started time.Time
+	elapsed time.Duration
+	order   []reportKey
+	rows    map[reportKey]*reportRow
+}
+
+type reportKey struct {
+	repo, directive string
+}
+
+type reportRow struct {
+	matched int
+	actions map[string]int
+}
+
+func (r *report) reset() {
+	r.mut.Lock()
+	r.started, r.elapsed, r.order, r.rows = time.Now(), 0, nil, nil
+	r.mut.Unlock()
+}
+
+func (r *report) done() {
+	r.mut.Lock()
+	r.elapsed = time.Since(r.started)
+	r.mut.Unlock()
+}
+
+// row returns the row for the key, adding it if needed. The lock must be
+// held.
+func (r *report) row(key reportKey) *reportRow {
+	if r.rows == nil {
+		r.rows = make(map[reportKey]*reportRow)
+	}
+	row, ok := r.rows[key]
+	if !ok {
+		row = &reportRow{actions: make(map[string]int)}
+		r.rows[key] = row
+		r.order = append(r.order, key)
+	}
+	return row
+}
+
+func (r *report) matched(owner, repo, directive string) {
+	r.mut.Lock()
+	r.row(reportKey{owner + "/" + repo, directive}).matched++
+	r.mut.Unlock()
+}
+
+func (r *report) acted(a Action) {
+	r.mut.Lock()
+	r.row(reportKey{a.Owner + "/" + a.Repo, a.Directive}).actions[a.Kind]++
+	r.mut.Unlock()
+}
+
+// reportColumns are the action kinds with a column of their own in the
+// summary; the rest are counted as other.
+var reportColumns = []struct {
+	name  string
+	kinds []string
+}{
+	{"LABELED", []string{ActionLabel}},
+	{"COMMENTED", []string{ActionComment, ActionDiscussionComment}},
+	{"CLOSED", []string{ActionClose}},
+	{"LOCKED", []string{ActionLock, ActionDiscussionLock}},
+}
+
+// WriteSummary writes a table of the issues matched and the actions taken
+// per repo and directive in the last run, followed by the number of API
+// requests made, as counted by the caller, and the time it took.
+func (r *Runner) WriteSummary(w io.Writer, apiRequests int64) {
+	rep := &r.report
+	rep.mut.Lock()
+	defer rep.mut.Unlock()
+
+	// Each repo is handled by one worker, so the directives of a repo
+	// are in order already.
+	order := append([]reportKey(nil), rep.order...)
+	sort.SliceStable(order, func(a, b int) bool { return order[a].repo < order[b].repo })
+
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "REPO\tDIRECTIVE\tMATCHED\t")
+	for _, c := range reportColumns {
+		fmt.Fprintf(tw, "%s\t", c.name)
+	}
+	fmt.Fprint(tw, "OTHER\n")
+
+	var total reportRow
+	total.actions = make(map[string]int)
+	for _, key := range order {
+		row := rep.rows[key]
+		writeReportRow(tw, key.repo, key.directive, row)
+		total.matched += row.matched
+		for kind, n := range row.actions {
+			total.actions[kind] += n
+		}
+	}
+	writeReportRow(tw, "Total", "", &total)
+	tw.Flush()
+
+	fmt.Fprintf(w, "%d API requests in %v\n", apiRequests, rep.elapsed.Round(time.Second))
+}
+
+func writeReportRow(w io.Writer, repo, directive string, row *reportRow) {
+	fmt.Fprintf(w, "%s\t%s\t%d\t", repo, directive, row.matched)
+	other := 0
+	for _, n := range row.actions {
+		other += n
+	}
+	for _, c := range reportColumns {
+		n := 0
+		for _, kind := range c.kinds {
+			n += row.actions[kind]
+		}
+		other -= n
+		fmt.Fprintf(w, "%d\t", n)
+	}
+	fmt.Fprintf(w, "%d\n", other)
+}
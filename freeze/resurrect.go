@@ -0,0 +1,141 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// Resurrect configures a directive that looks at issues we closed earlier
+// and reopens, or labels, those that drew interest after closing.
+type Resurrect struct {
+	// Reactions is the number of new 👍 reactions after closing that
+	// triggers the directive, or zero to not count reactions.
+	Reactions int
+	// Comments is the number of new comments after closing that triggers
+	// the directive, or zero to not count comments.
+	Comments int
+	// Label, if set, is added to the issue instead of reopening it.
+	Label string
+}
+
+func closedKey(owner, repo string, number int) string {
+	return fmt.Sprintf("%s/%s#%d", owner, repo, number)
+}
+
+// resurrect applies a Resurrect directive to the issues of the repo that
+// the state says we closed.
+func (r *Runner) resurrect(ctx context.Context, owner, repo string, directive Directive, sink ActionSink) error {
+	for number, closedAt := range r.State.repoIssues(r.State.Closed, owner, repo) {
+		i, _, err := r.Client.Issues.Get(ctx, owner, repo, number)
+		if err != nil {
+			return fmt.Errorf("getting issue %d: %w", number, classifyAPIError(err))
+		}
+		if i.GetState() != "closed" || i.GetClosedAt().After(closedAt.Add(time.Minute)) {
+			// Reopened, or closed again by someone else; no longer
+			// ours to watch.
+			r.State.with(func() { delete(r.State.Closed, closedKey(owner, repo, number)) })
+			continue
+		}
+
+		why, err := r.resurrectionReason(ctx, owner, repo, number, closedAt, directive.Resurrect)
+		if err != nil {
+			return err
+		}
+		if why == "" {
+			continue
+		}
+
+		debugf("Issue %d has %s since closing", number, why)
+		a := Action{Owner: owner, Repo: repo, Issue: number, Directive: directive.Name, IssueUpdatedAt: i.GetUpdatedAt(), IssueNodeID: i.GetNodeID()}
+		if directive.Resurrect.Label != "" {
+			if contains(i.Labels, directive.Resurrect.Label) {
+				continue
+			}
+			a.Kind, a.Label = ActionLabel, directive.Resurrect.Label
+		} else {
+			a.Kind = ActionReopen
+		}
+		if err := sink.Act(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resurrectionReason returns a description of the interest an issue drew
+// after closing, if enough to trigger the directive, or the empty string.
+func (r *Runner) resurrectionReason(ctx context.Context, owner, repo string, number int, closedAt time.Time, res *Resurrect) (string, error) {
+	if res.Comments > 0 {
+		n, err := r.countCommentsSince(ctx, owner, repo, number, closedAt)
+		if err != nil {
+			return "", fmt.Errorf("counting comments on issue %d: %w", number, err)
+		}
+		if n >= res.Comments {
+			return fmt.Sprintf("%d new comments", n), nil
+		}
+	}
+	if res.Reactions > 0 {
+		n, err := r.countReactionsSince(ctx, owner, repo, number, closedAt)
+		if err != nil {
+			return "", fmt.Errorf("counting reactions on issue %d: %w", number, err)
+		}
+		if n >= res.Reactions {
+			return fmt.Sprintf("%d new 👍 reactions", n), nil
+		}
+	}
+	return "", nil
+}
+
+func (r *Runner) countCommentsSince(ctx context.Context, owner, repo string, number int, since time.Time) (int, error) {
+	n := 0
+	opts := &github.IssueListCommentsOptions{Since: since, ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := r.Client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return 0, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			if c.GetCreatedAt().After(since) {
+				n++
+			}
+		}
+		if resp.NextPage == 0 {
+			return n, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func (r *Runner) countReactionsSince(ctx context.Context, owner, repo string, number int, since time.Time) (int, error) {
+	n := 0
+	page := 1
+	for {
+		// Our version of the client library doesn't decode the reaction
+		// timestamps.
+		u := fmt.Sprintf("repos/%s/%s/issues/%d/reactions?content=%%2B1&per_page=%d&page=%d", owner, repo, number, perPage, page)
+		req, err := r.Client.NewRequest("GET", u, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Accept", "application/vnd.github.squirrel-girl-preview+json")
+		var reactions []struct {
+			CreatedAt time.Time `json:"created_at"`
+		}
+		resp, err := r.Client.Do(ctx, req, &reactions)
+		if err != nil {
+			return 0, classifyAPIError(err)
+		}
+		for _, re := range reactions {
+			if re.CreatedAt.After(since) {
+				n++
+			}
+		}
+		if resp.NextPage == 0 {
+			return n, nil
+		}
+		page = resp.NextPage
+	}
+}
@@ -0,0 +1,954 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	retries = 5
+	perPage = 100
+)
+
+// A Runner applies configurations to the repos on GitHub.
+type Runner struct {
+	Client *github.Client
+	// Clock is used for all threshold comparisons; the real clock if nil.
+	Clock Clock
+	// State, if set, holds checkpoints and is saved after each repo.
+	State *State
+	// RepoTimeBudget is the maximum time to spend on a single repo per
+	// run, or zero for unlimited.
+	RepoTimeBudget time.Duration
+	// PageConcurrency is the number of issue pages to fetch ahead
+	// concurrently within a repo. It applies to search queries and, with
+	// REST, to listings; GraphQL listings are fetched one page at a time,
+	// as each page is reached by the cursor of the previous one.
+	PageConcurrency int
+	// REST, if set, lists issues with the REST API instead of GraphQL.
+	// GraphQL pages are reached by cursor and so fetched one at a time.
+	REST bool
+	// Concurrency is the number of repos to process in parallel. Use it
+	// with a pacing transport, so that the repos together don't trip the
+	// secondary rate limits. Actions from different repos are then
+	// interleaved.
+	Concurrency int
+	// ProgressInterval, if set, is how often to log a progress line
+	// during a run.
+	ProgressInterval time.Duration
+	// AsOf, if set, simulates a run at that time in the past: issues are
+	// reconstructed as they were then from their timelines. Use together
+	// with a Clock fixed at the same time, and a sink that doesn't act.
+	AsOf time.Time
+	// Sink receives the actions as they are decided; a GitHubSink using
+	// Client if nil.
+	Sink ActionSink
+	// MembershipTTL, if set, is how long org member and collaborator lists
+	// are kept in the state across runs. They're always cached within a
+	// run.
+	MembershipTTL time.Duration
+
+	repos     repoCache
+	members   memberCache
+	summaries summaries
+	batches   batches
+	caps      caps
+	report    report
+	allowed   Config // for its AllowedActions
+}
+
+// Run applies the configuration, passing the actions to the sink as they
+// are decided.
+func (r *Runner) Run(ctx context.Context, cfg Config) error {
+	return r.walk(ctx, cfg, r.sink())
+}
+
+func (r *Runner) sink() ActionSink {
+	if r.Sink == nil {
+		return &GitHubSink{Client: r.Client, State: r.State, Clock: r.Clock}
+	}
+	return r.Sink
+}
+
+// walk goes through every repo and matching issue of the configuration and
+// passes the resulting actions to the sink.
+func (r *Runner) walk(ctx context.Context, cfg Config, sink ActionSink) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	if r.State == nil {
+		r.State, _ = LoadState("")
+	}
+
+	sink = &summarySink{summaries: &r.summaries, next: sink}
+	if len(cfg.AllowedActions) > 0 {
+		sink = &allowedSink{cfg: cfg, next: sink}
+	}
+	r.allowed = Config{AllowedActions: cfg.AllowedActions}
+	r.summaries.reset()
+	r.batches.reset()
+	r.caps.reset(cfg.MaxActions)
+	r.report.reset()
+	defer r.report.done()
+
+	// List all repos up front, so that we know the totals for progress
+	// reporting.
+	var entries, campaigns [][]string
+	total := 0
+	cfg.Entries = append([]Entry(nil), cfg.Entries...)
+	for i, entry := range cfg.Entries {
+		repos, err := r.repoNames(ctx, entry)
+		if err != nil {
+			return err
+		}
+		cfg.Entries[i], repos, err = r.orgSearch(ctx, entry, repos)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, repos)
+		total += len(repos)
+	}
+	cfg.Campaigns = append([]Campaign(nil), cfg.Campaigns...)
+	for i, c := range cfg.Campaigns {
+		var repos []string
+		if c.active(r.Clock.Now(), r.State) {
+			var err error
+			repos, err = r.repoNames(ctx, c.Entry)
+			if err != nil {
+				return err
+			}
+			cfg.Campaigns[i].Entry, repos, err = r.orgSearch(ctx, c.Entry, repos)
+			if err != nil {
+				return err
+			}
+		}
+		campaigns = append(campaigns, repos)
+		total += len(repos)
+	}
+
+	prog := newProgress(total)
+	failures := &failureSink{next: &countingSink{prog: prog, report: &r.report, next: sink}}
+	sink = failures
+	if r.ProgressInterval > 0 {
+		stop := prog.report(r.ProgressInterval)
+		defer stop()
+	}
+
+	var failedMut sync.Mutex
+	failed := 0
+	handleRepo := func(owner, repo string, e Entry) error {
+		infof("Processing %s/%s", owner, repo)
+		prog.startRepo(owner, repo, len(e.Directives))
+		err := r.processRepo(ctx, owner, repo, e, sink, prog)
+		prog.doneRepo()
+		if err := r.State.Save(); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+		if isFatal(err) {
+			return fmt.Errorf("processing %s/%s: %w", owner, repo, err)
+		} else if err != nil {
+			warnf("Processing %s/%s: %v", owner, repo, err)
+			failedMut.Lock()
+			failed++
+			failedMut.Unlock()
+		}
+		return nil
+	}
+
+	for i, entry := range cfg.Entries {
+		err := r.eachRepo(entries[i], func(repo string) error {
+			return handleRepo(entry.Owner, repo, entry)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, c := range cfg.Campaigns {
+		if !c.active(r.Clock.Now(), r.State) {
+			continue
+		}
+		infof("Running campaign %s", c.Name)
+		failedBefore, failuresBefore := failed, failures.count()
+		err := r.eachRepo(campaigns[i], func(repo string) error {
+			return handleRepo(c.Owner, repo, c.Entry)
+		})
+		if err != nil {
+			return err
+		}
+		if failed == failedBefore && failures.count() == failuresBefore && c.finished(r.State) {
+			if r.State.readOnly {
+				// A dry run or plan; nothing was done.
+				infof("Campaign %s would be complete", c.Name)
+				continue
+			}
+			infof("Campaign %s is complete", c.Name)
+			r.State.Campaigns[c.Name] = r.Clock.Now()
+			if err := r.State.Save(); err != nil {
+				return fmt.Errorf("saving state: %w", err)
+			}
+		}
+	}
+
+	if err := r.summaries.flush(ctx, sink, r.Clock.Now().Format("2006-01-02")); err != nil {
+		return err
+	}
+
+	if failed > 0 || failures.count() > 0 {
+		return &PartialFailureError{Failed: failed, FailedActions: failures.count()}
+	}
+	return nil
+}
+
+// eachRepo calls fn for each of the repos, on up to Concurrency of them at
+// a time, returning the first error. No more repos are started after an
+// error.
+func (r *Runner) eachRepo(repos []string, fn func(repo string) error) error {
+	workers := r.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	var mut sync.Mutex
+	var firstErr error
+	failed := func() bool {
+		mut.Lock()
+		defer mut.Unlock()
+		return firstErr != nil
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for n := 0; n < workers; n++ {
+		go func() {
+			defer wg.Done()
+			for repo := range queue {
+				if err := fn(repo); err != nil {
+					mut.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mut.Unlock()
+				}
+			}
+		}()
+	}
+	for _, repo := range repos {
+		if failed() {
+			break
+		}
+		queue <- repo
+	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
+
+// repoNames returns the repos of the entry; the listed ones, or else all
+// repos of the owner.
+func (r *Runner) repoNames(ctx context.Context, entry Entry) ([]string, error) {
+	if len(entry.Repos) > 0 {
+		return entry.Repos, nil
+	}
+
+	listOpts := &github.RepositoryListOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	var names []string
+	for {
+		rs, resp, err := r.Client.Repositories.List(ctx, entry.Owner, listOpts)
+		if err != nil {
+			return nil, fmt.Errorf("listing repos: %w", classifyAPIError(err))
+		}
+
+		for _, repo := range rs {
+			names = append(names, repo.GetName())
+		}
+
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		listOpts.Page = resp.NextPage
+	}
+}
+
+// processRepo handles the repo, converting a panic into an error so that
+// one malformed issue doesn't take down the whole run.
+func (r *Runner) processRepo(ctx context.Context, owner, repo string, e Entry, sink ActionSink, prog *progress) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			warnf("Panic processing %s/%s: %v\n%s", owner, repo, p, debug.Stack())
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	if err := r.handleRepoIssues(ctx, owner, repo, e.Directives, sink, prog); err != nil {
+		return err
+	}
+	return r.handleRepoDiscussions(ctx, owner, repo, e.Discussions, sink)
+}
+
+// handleRepoIssues applies the directives to the repo. If the repo time
+// budget is non-zero and gets exceeded, the current position is checkpointed
+// in the state and the rest of the repo is left for the next run.
+func (r *Runner) handleRepoIssues(ctx context.Context, owner, repo string, directives []Directive, sink ActionSink, prog *progress) error {
+	var deadline time.Time
+	if r.RepoTimeBudget > 0 {
+		deadline = time.Now().Add(r.RepoTimeBudget)
+	}
+
+	lc := newListCache(directives)
+	for idx, directive := range directives {
+		prog.startDirective(idx)
+		if !directive.searchedIn(repo) {
+			continue
+		}
+		if directive.UnlockAfterDays > 0 {
+			if err := r.unlockExpired(ctx, owner, repo, directive, sink); err != nil {
+				return err
+			}
+			continue
+		}
+		if directive.Resurrect != nil {
+			if err := r.resurrect(ctx, owner, repo, directive, sink); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := checkpointKey(directive.scope, owner, repo, idx)
+		var start int
+		r.State.with(func() { start = r.State.Checkpoints[key] })
+		if start > 1 {
+			infof("Resuming directive %d at page %d", idx, start)
+		} else {
+			start = 1
+		}
+
+		if r.caps.full(directive) {
+			infof("Skipping directive %s for %s/%s; action cap reached", directive.Name, owner, repo)
+			continue
+		}
+
+		next := 0
+		batchFull := false
+		capped, remaining := false, 0
+		matching := 0
+		// removed counts the issues our actions took out of the
+		// listing, moving the later ones up, so that we resume where
+		// the first unhandled issue will be next run.
+		removed := 0
+		resume := func(offset, n int) int {
+			pos := offset + n - removed
+			if pos < 0 {
+				pos = 0
+			}
+			return pos/perPage + 1
+		}
+		var handleErr error
+		var pool *actionPool
+		if directive.Workers > 1 {
+			pool = newActionPool(ctx, sink, directive.Workers)
+			// Stops the workers also when we return early or panic.
+			defer pool.wait()
+		}
+		err := r.findIssues(ctx, owner, repo, directive, start, lc, func(offset int, issues []github.Issue) (int, bool) {
+			prog.setPage(offset/perPage + 1)
+			before := removed
+			for n, i := range issues {
+				if pastDeadline(deadline) {
+					next = resume(offset, n)
+					return removed - before, false
+				}
+				if !r.AsOf.IsZero() {
+					var existed bool
+					i, existed, handleErr = r.asOf(ctx, owner, repo, i)
+					if handleErr != nil {
+						return removed - before, false
+					}
+					if !existed {
+						continue
+					}
+				}
+				ok, err := r.matchesActivity(ctx, owner, repo, i, directive)
+				if err != nil {
+					handleErr = err
+					return removed - before, false
+				}
+				if !ok {
+					continue
+				}
+				matching++
+				r.report.matched(owner, repo, directive.Name)
+				if !r.batches.take(directive) {
+					next, batchFull = resume(offset, n), true
+					return removed - before, false
+				}
+				actions, err := r.decide(ctx, owner, repo, i, directive)
+				if err != nil {
+					handleErr = err
+					return removed - before, false
+				}
+				if len(actions) > 0 && !r.caps.take(directive, len(actions)) {
+					next, capped = resume(offset, n), true
+					// Count what's left on the page, roughly, for the
+					// log; we don't page on for it.
+					remaining = 1
+					for _, i := range issues[n+1:] {
+						if r.matches(i, directive) {
+							remaining++
+						}
+					}
+					return removed - before, false
+				}
+				if lc != nil {
+					applyLocally(&issues[n], actions, r.Clock.Now())
+				}
+				if removesFromListing(actions) {
+					removed++
+				}
+				if pool != nil {
+					if err := pool.submit(actions); err != nil {
+						handleErr = err
+						return removed - before, false
+					}
+				} else {
+					for _, a := range actions {
+						if err := sink.Act(ctx, a); err != nil {
+							handleErr = err
+							return removed - before, false
+						}
+					}
+				}
+				if len(actions) > 0 {
+					if err := batchPause(ctx, directive); err != nil {
+						handleErr = err
+						return removed - before, false
+					}
+				}
+			}
+			if pool != nil && removed > before {
+				// The next page is fetched where the removals leave
+				// it, so they must have happened.
+				if err := pool.flush(); err != nil {
+					handleErr = err
+					return removed - before, false
+				}
+			}
+			return removed - before, true
+		})
+		if pool != nil {
+			if err := pool.wait(); err != nil && handleErr == nil {
+				handleErr = err
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("finding issues: %w", classifyAPIError(err))
+		}
+		if handleErr != nil {
+			return handleErr
+		}
+
+		if capped {
+			r.State.with(func() { r.State.Checkpoints[key] = next })
+			infof("Action cap reached for directive %s in %s/%s, with at least %d matching issues left for the next run", directive.Name, owner, repo, remaining)
+			continue
+		}
+		if batchFull {
+			r.State.with(func() { r.State.Checkpoints[key] = next })
+			infof("Batch of %d issues done for %s; %s/%s continues next run", directive.batchSize, directive.scope, owner, repo)
+			return nil
+		}
+		if next > 0 {
+			r.State.with(func() { r.State.Checkpoints[key] = next })
+			infof("Time budget of %v exceeded for %s/%s; will resume at directive %d page %d next run", r.RepoTimeBudget, owner, repo, idx, next)
+			return nil
+		}
+		r.State.with(func() { delete(r.State.Checkpoints, key) })
+		if start == 1 {
+			// Only a pass over all pages gives the full count.
+			metricMatchingIssues.WithLabelValues(owner, repo, directive.Name).Set(float64(matching))
+		}
+	}
+	return nil
+}
+
+// removesFromListing returns true if the actions take the issue out of
+// the listings we page through, moving later issues up. It errs on the
+// side of true, which at worst makes us resume a page early.
+func removesFromListing(actions []Action) bool {
+	for _, a := range actions {
+		switch a.Kind {
+		case ActionClose, ActionReopen, ActionTransfer, ActionDiscussion, ActionLock, ActionUnlock:
+			return true
+		}
+	}
+	return false
+}
+
+func pastDeadline(deadline time.Time) bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+// matches returns true if the directive should be applied to the issue.
+func (r *Runner) matches(i github.Issue, directive Directive) bool {
+	return r.matchesEvents(i, directive, nil)
+}
+
+// matchesEvents is like matches, with the issue's timeline for the When
+// expression.
+func (r *Runner) matchesEvents(i github.Issue, directive Directive, evs []timelineEvent) bool {
+	now := r.Clock.Now()
+	skip := func(why string, args ...interface{}) bool {
+		debugf("Skipping issue %d for directive %s; %s", i.GetNumber(), directive.Name, fmt.Sprintf(why, args...))
+		return false
+	}
+
+	if i.GetLocked() != directive.Unlock {
+		// Never touch locked issues, except to unlock them
+		if directive.Unlock {
+			return skip("not locked")
+		}
+		return skip("locked")
+	}
+	if directive.Query == "" && (directive.State == "" || directive.State == "open") && i.GetState() == "closed" {
+		// Closed by an earlier directive, since the listing was cached
+		return skip("closed by an earlier directive")
+	}
+	if !directive.reached(now, i.GetClosedAt(), directive.DaysClosed) {
+		// Check days closed if set
+		return skip("closed %s", i.GetClosedAt().Format(time.RFC3339))
+	}
+	if !directive.warned(i) && !directive.reached(now, i.GetUpdatedAt(), directive.DaysNotUpdated) {
+		// Check days not updated if set; warned issues are counted from
+		// the warning instead, when deciding
+		return skip("updated %s", i.GetUpdatedAt().Format(time.RFC3339))
+	}
+	if directive.ItemType == itemIssue && i.IsPullRequest() || directive.ItemType == itemPR && !i.IsPullRequest() {
+		return skip("not of item type %s", directive.ItemType)
+	}
+	if directive.NeedsInfo != "" && !contains(i.Labels, directive.NeedsInfo) {
+		return skip("not labeled %q", directive.NeedsInfo)
+	}
+	if !directive.matchesPatterns(i) {
+		return skip("no pattern matches")
+	}
+	if directive.exemptMilestone(i) {
+		// Planned work
+		return skip("in milestone %q", i.GetMilestone().GetTitle())
+	}
+	if directive.when != nil {
+		ok, err := directive.evalWhen(now, i, evs)
+		if err != nil {
+			warnf("Evaluating condition for issue %d: %v", i.GetNumber(), err)
+			return false
+		}
+		if !ok {
+			return skip("condition not met")
+		}
+	}
+	return true
+}
+
+// matchesActivity is matches, with the days not updated counted from the
+// last human comment in place of the last update when the directive says
+// so.
+func (r *Runner) matchesActivity(ctx context.Context, owner, repo string, i github.Issue, directive Directive) (bool, error) {
+	if directive.DaysLocked > 0 && i.GetLocked() {
+		lockedAt, err := lastEvent(ctx, r.Client, owner, repo, i.GetNumber(), func(ev timelineEvent) bool {
+			return ev.Event == "locked"
+		})
+		if err != nil {
+			return false, fmt.Errorf("checking lock of issue %d: %w", i.GetNumber(), err)
+		}
+		if lockedAt.IsZero() {
+			lockedAt = i.GetUpdatedAt()
+		}
+		if !directive.reached(r.Clock.Now(), lockedAt, directive.DaysLocked) {
+			debugf("Skipping issue %d for directive %s; locked %s", i.GetNumber(), directive.Name, lockedAt.Format(time.RFC3339))
+			return false, nil
+		}
+	}
+	if directive.Activity == activityHuman && directive.DaysNotUpdated > 0 {
+		t, err := lastHumanActivity(ctx, r.Client, owner, repo, i, r.Clock.Now())
+		if err != nil {
+			return false, fmt.Errorf("checking activity on issue %d: %w", i.GetNumber(), err)
+		}
+		i.UpdatedAt = &t
+	}
+	if directive.NeedsInfo != "" && contains(i.Labels, directive.NeedsInfo) {
+		t, err := r.needsInfoSince(ctx, owner, repo, i, directive.NeedsInfo)
+		if err != nil {
+			return false, fmt.Errorf("checking %q on issue %d: %w", directive.NeedsInfo, i.GetNumber(), err)
+		}
+		i.UpdatedAt = &t
+	}
+	if directive.DaysClosedByHuman > 0 && i.GetState() == "closed" {
+		now, closed := r.Clock.Now(), i.GetClosedAt()
+		if directive.reached(now, closed, directive.DaysClosed) != directive.reached(now, closed, directive.DaysClosedByHuman) {
+			byBot, err := r.closedByBot(ctx, owner, repo, i)
+			if err != nil {
+				return false, fmt.Errorf("checking who closed issue %d: %w", i.GetNumber(), err)
+			}
+			if !byBot {
+				directive.DaysClosed = directive.DaysClosedByHuman
+			}
+		} else if !directive.reached(now, closed, directive.DaysClosed) {
+			debugf("Skipping issue %d for directive %s; closed %s", i.GetNumber(), directive.Name, closed.Format(time.RFC3339))
+			return false, nil
+		}
+	}
+	if directive.whenEvents {
+		evs, err := listTimeline(ctx, r.Client, owner, repo, i.GetNumber())
+		if err != nil {
+			return false, fmt.Errorf("listing events of issue %d: %w", i.GetNumber(), classifyAPIError(err))
+		}
+		return r.matchesEvents(i, directive, evs), nil
+	}
+	return r.matches(i, directive), nil
+}
+
+// decide returns the actions the directive calls for on a matching issue.
+func (r *Runner) decide(ctx context.Context, owner, repo string, i github.Issue, directive Directive) ([]Action, error) {
+	actions, err := r.decideActions(ctx, owner, repo, i, directive)
+	if err != nil {
+		return nil, err
+	}
+	if directive.Quiet {
+		actions = directive.quiet(actions)
+	}
+	actions = r.allowed.allowedActions(actions)
+	addSnapshots(actions, i, r.Clock.Now())
+	return actions, nil
+}
+
+func (r *Runner) decideActions(ctx context.Context, owner, repo string, i github.Issue, directive Directive) ([]Action, error) {
+	base := Action{Owner: owner, Repo: repo, Issue: i.GetNumber(), Directive: directive.Name, IssueUpdatedAt: i.GetUpdatedAt(), IssueNodeID: i.GetNodeID()}
+	switch {
+	case directive.script != nil:
+		return directive.scriptActions(base, r.Clock.Now(), i)
+	case directive.plugin != nil:
+		return directive.pluginActions(ctx, base, r.Clock.Now(), i)
+	case directive.SlashCommands != nil:
+		return r.slashActions(ctx, base, i, directive.SlashCommands)
+	case directive.EOL != nil:
+		return r.eolActions(ctx, base, i, directive)
+	}
+
+	var actions []Action
+	add := func(kind string, mod func(*Action)) {
+		a := base
+		a.Kind = kind
+		if mod != nil {
+			mod(&a)
+		}
+		actions = append(actions, a)
+	}
+
+	if directive.HonorCommands {
+		snoozed, err := r.snoozed(ctx, owner, repo, i.GetNumber())
+		if err != nil {
+			return nil, fmt.Errorf("checking commands on issue %d: %w", i.GetNumber(), err)
+		}
+		if snoozed {
+			debugf("Skipping issue %d; snoozed by a maintainer", i.GetNumber())
+			return nil, nil
+		}
+	}
+
+	if directive.ExemptMembers {
+		member, err := r.isMember(ctx, owner, repo, i.GetUser().GetLogin())
+		if err != nil {
+			return nil, fmt.Errorf("checking membership for issue %d: %w", i.GetNumber(), err)
+		}
+		if member {
+			return nil, nil
+		}
+	}
+
+	if len(directive.ExemptAuthors) > 0 {
+		author, err := r.listed(ctx, owner, []string{i.GetUser().GetLogin()}, directive.ExemptAuthors)
+		if err != nil {
+			return nil, fmt.Errorf("checking author of issue %d: %w", i.GetNumber(), err)
+		}
+		if author != "" {
+			return nil, nil
+		}
+	}
+
+	if len(directive.ExemptAssignees) > 0 {
+		var logins []string
+		for _, u := range i.Assignees {
+			logins = append(logins, u.GetLogin())
+		}
+		assignee, err := r.listed(ctx, owner, logins, directive.ExemptAssignees)
+		if err != nil {
+			return nil, fmt.Errorf("checking assignees of issue %d: %w", i.GetNumber(), err)
+		}
+		if assignee != "" {
+			debugf("Skipping issue %d; assigned to %s", i.GetNumber(), assignee)
+			return nil, nil
+		}
+	}
+
+	if len(directive.ExemptIfCommentedByTeam) > 0 {
+		days := directive.DaysNotUpdated
+		if days == 0 {
+			days = directive.DaysClosed
+		}
+		var since time.Time
+		if days > 0 {
+			since = r.Clock.Now().Add(-time.Duration(days) * 24 * time.Hour)
+		}
+		commented, err := r.commentedByTeam(ctx, owner, repo, i.GetNumber(), directive.ExemptIfCommentedByTeam, since)
+		if err != nil {
+			return nil, fmt.Errorf("checking team comments for issue %d: %w", i.GetNumber(), err)
+		}
+		if commented {
+			return nil, nil
+		}
+	}
+
+	if directive.ExemptIfReferencedDays > 0 {
+		since := r.Clock.Now().Add(-time.Duration(directive.ExemptIfReferencedDays) * 24 * time.Hour)
+		ref, err := externalReference(ctx, r.Client, owner, repo, i.GetNumber(), since)
+		if err != nil {
+			return nil, fmt.Errorf("checking references to issue %d: %w", i.GetNumber(), err)
+		}
+		if ref != "" {
+			debugf("Skipping issue %d; recently referenced by %s", i.GetNumber(), ref)
+			return nil, nil
+		}
+	}
+
+	if directive.Unlock && i.GetLocked() {
+		add(ActionUnlock, nil)
+	}
+
+	if directive.warnComment != nil {
+		warning, proceed, err := r.warnActions(ctx, base, i, directive)
+		if err != nil {
+			return nil, fmt.Errorf("checking warning on issue %d: %w", i.GetNumber(), err)
+		}
+		if !proceed {
+			return warning, nil
+		}
+	}
+
+	if typ := directive.issueType(i); typ != "" {
+		cur, err := issueType(ctx, r.Client, i.GetNodeID())
+		if err != nil {
+			return nil, fmt.Errorf("checking type of issue %d: %w", i.GetNumber(), err)
+		}
+		if cur == "" {
+			add(ActionType, func(a *Action) { a.IssueType = typ })
+		}
+	}
+
+	if directive.ConvertToDiscussion != "" {
+		add(ActionDiscussion, func(a *Action) {
+			a.Category = directive.ConvertToDiscussion
+			a.Title = i.GetTitle()
+			a.Body = discussionBody(i.GetUser().GetLogin(), i.GetHTMLURL(), i.GetBody())
+		})
+	}
+
+	if directive.Label != "" && !contains(i.Labels, directive.Label) {
+		add(ActionLabel, func(a *Action) { a.Label = directive.Label })
+	}
+	for _, label := range directive.RemoveLabels {
+		if contains(i.Labels, label) {
+			add(ActionUnlabel, func(a *Action) { a.Label = label })
+		}
+	}
+	if len(directive.AgeLabels) > 0 && i.GetState() == "open" {
+		age := directive.daysSince(r.Clock.Now(), i.GetCreatedAt())
+		want := ""
+		for _, al := range directive.AgeLabels {
+			if al.Days <= age {
+				want = al.Label
+			}
+		}
+		for _, al := range directive.AgeLabels {
+			has := contains(i.Labels, al.Label)
+			if al.Label == want && !has {
+				add(ActionLabel, func(a *Action) { a.Label = al.Label })
+			} else if al.Label != want && has {
+				add(ActionUnlabel, func(a *Action) { a.Label = al.Label })
+			}
+		}
+	}
+
+	assignees := len(i.Assignees)
+	if directive.Unassign && assignees > 0 {
+		var logins []string
+		for _, u := range i.Assignees {
+			logins = append(logins, u.GetLogin())
+		}
+		add(ActionUnassign, func(a *Action) { a.Assignees = logins })
+		assignees = 0
+	}
+	if len(directive.Assign) > 0 && assignees == 0 {
+		login := directive.Assign[i.GetNumber()%len(directive.Assign)]
+		add(ActionAssign, func(a *Action) { a.Assignees = []string{login} })
+	}
+	if directive.SetMilestone != "" && !strings.EqualFold(i.GetMilestone().GetTitle(), directive.SetMilestone) {
+		add(ActionMilestone, func(a *Action) { a.Milestone = directive.SetMilestone })
+	}
+
+	if (directive.Pin || directive.Unpin) && !i.IsPullRequest() {
+		pinned, err := isPinned(ctx, r.Client, i.GetNodeID())
+		if err != nil {
+			return nil, fmt.Errorf("checking issue %d: %w", i.GetNumber(), err)
+		}
+		if directive.Unpin && pinned {
+			add(ActionUnpin, nil)
+		}
+		if directive.Pin && !pinned {
+			add(ActionPin, nil)
+		}
+	}
+
+	closing := directive.Close && i.GetState() != "closed"
+	if closing && len(directive.NoCloseFor) > 0 {
+		courtesy, err := r.courtesy(ctx, owner, repo, i.GetUser().GetLogin(), directive.NoCloseFor)
+		if err != nil {
+			return nil, fmt.Errorf("checking author of issue %d: %w", i.GetNumber(), err)
+		}
+		if courtesy != "" {
+			debugf("Not closing issue %d; author is one of the %s", i.GetNumber(), courtesy)
+			closing = false
+		}
+	}
+	if closing && directive.TrackedTasks != "" {
+		parent, err := trackingIssue(ctx, r.Client, owner, repo, i.GetNumber())
+		if err != nil {
+			return nil, fmt.Errorf("checking task lists for issue %d: %w", i.GetNumber(), err)
+		}
+		if parent != "" {
+			debugf("Not closing issue %d; it's an open task in %s", i.GetNumber(), parent)
+			closing = false
+			if directive.TrackedTasks == trackedTasksFlag && !contains(i.Labels, directive.TrackedTaskLabel) {
+				add(ActionLabel, func(a *Action) { a.Label = directive.TrackedTaskLabel })
+			}
+		}
+	}
+
+	if closing && directive.SummaryIssue != "" {
+		add(ActionClose, func(a *Action) { a.Summary = newSummaryLine(directive.summaryIssue, owner, repo, i) })
+		closing = false
+	}
+
+	if closing {
+		tmpl := directive.closeComment
+		if len(directive.closeCommentByAssociation) > 0 {
+			assoc, err := authorAssociation(ctx, r.Client, owner, repo, i.GetNumber())
+			if err != nil {
+				return nil, fmt.Errorf("checking author association for issue %d: %w", i.GetNumber(), err)
+			}
+			if t, ok := directive.closeCommentByAssociation[assoc]; ok {
+				tmpl = t
+			}
+		}
+		if tmpl != nil {
+			text, err := r.renderComment(ctx, tmpl, owner, repo, issueCommentData(directive, r.Clock.Now(), i))
+			if err != nil {
+				return nil, fmt.Errorf("rendering close comment: %w", err)
+			}
+			add(ActionComment, func(a *Action) {
+				a.Comment = directive.comment(text)
+				a.Reaction = directive.CommentReaction
+			})
+		}
+		add(ActionClose, nil)
+	}
+
+	if directive.Draft && i.GetState() == "open" {
+		draft, err := isDraft(ctx, r.Client, i.GetNodeID())
+		if err != nil {
+			return nil, fmt.Errorf("checking pull request %d: %w", i.GetNumber(), err)
+		}
+		if !draft {
+			add(ActionDraft, nil)
+		}
+	}
+
+	if directive.Lock && directive.LockSummary != nil {
+		text, err := r.lockSummary(ctx, owner, repo, i, directive)
+		if err != nil {
+			return nil, fmt.Errorf("summarizing issue %d: %w", i.GetNumber(), err)
+		}
+		if text != "" {
+			add(ActionComment, func(a *Action) { a.Comment = directive.comment(text) })
+		}
+	}
+
+	if directive.Lock {
+		add(ActionLock, func(a *Action) { a.LockReason = directive.LockReason })
+	}
+
+	transferring := directive.TransferTo != "" && !i.IsPullRequest()
+	if transferring && directive.transferComment != nil {
+		text, err := r.renderComment(ctx, directive.transferComment, owner, repo, issueCommentData(directive, r.Clock.Now(), i))
+		if err != nil {
+			return nil, fmt.Errorf("rendering transfer comment: %w", err)
+		}
+		add(ActionComment, func(a *Action) {
+			a.Comment = directive.comment(text)
+			a.Reaction = directive.CommentReaction
+		})
+	}
+
+	if transferring {
+		add(ActionTransfer, func(a *Action) { a.TransferTo = directive.TransferTo })
+	}
+
+	return actions, nil
+}
+
+// matchesPatterns returns true if the directive has no patterns, or one of
+// them matches the title or body of the issue.
+func (d Directive) matchesPatterns(i github.Issue) bool {
+	if len(d.patterns) == 0 {
+		return true
+	}
+	for _, re := range d.patterns {
+		if re.MatchString(i.GetTitle()) || re.MatchString(i.GetBody()) {
+			return true
+		}
+	}
+	return false
+}
+
+// exemptMilestone returns true if the issue is in an open milestone that the
+// directive exempts.
+func (d Directive) exemptMilestone(i github.Issue) bool {
+	m := i.GetMilestone()
+	if m == nil || m.GetState() != "open" {
+		return false
+	}
+	for _, name := range d.ExemptMilestones {
+		if name == "any" || name == m.GetTitle() {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(l []github.Label, t string) bool {
+	for _, s := range l {
+		if s.GetName() == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package freeze
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// loadScript loads a Starlark script and returns its decide function.
+func loadScript(path string) (*starlark.Function, error) {
+	thread := &starlark.Thread{Name: path}
+	globals, err := starlark.ExecFile(thread, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := globals["decide"].(*starlark.Function)
+	if !ok {
+		return nil, fmt.Errorf("%s: no decide function", path)
+	}
+	return fn, nil
+}
+
+// scriptActions calls the directive's script with the issue and converts
+// the returned list into actions. Each element is either an action kind
+// ("close", "lock") or a dict with "kind" and, as relevant, "label",
+// "comment", "transfer_to" or "lock_reason" keys.
+func (d Directive) scriptActions(base Action, now time.Time, i github.Issue) ([]Action, error) {
+	thread := &starlark.Thread{Name: d.Script}
+	res, err := starlark.Call(thread, d.script, starlark.Tuple{scriptIssue(d, now, i)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Script, err)
+	}
+
+	list, ok := res.(*starlark.List)
+	if !ok {
+		return nil, fmt.Errorf("%s: decide returned %s, not a list", d.Script, res.Type())
+	}
+
+	var actions []Action
+	for n := 0; n < list.Len(); n++ {
+		a := base
+		switch v := list.Index(n).(type) {
+		case starlark.String:
+			a.Kind = string(v)
+		case *starlark.Dict:
+			a.Kind = dictString(v, "kind")
+			a.Label = dictString(v, "label")
+			a.Comment = dictString(v, "comment")
+			a.TransferTo = dictString(v, "transfer_to")
+			a.LockReason = dictString(v, "lock_reason")
+		default:
+			return nil, fmt.Errorf("%s: unexpected action %s", d.Script, v)
+		}
+		if err := checkDecidedAction(&a); err != nil {
+			return nil, fmt.Errorf("%s: %w", d.Script, err)
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// checkDecidedAction validates an action returned by a script or plugin as
+// the corresponding directive settings are validated in the config. A
+// TransferTo without an owner is in the owner of the issue. Kinds that
+// need more than a script or plugin can give are refused.
+func checkDecidedAction(a *Action) error {
+	switch a.Kind {
+	case ActionLabel, ActionUnlabel:
+		if a.Label == "" {
+			return fmt.Errorf("%s action without a label", a.Kind)
+		}
+	case ActionComment:
+		if a.Comment == "" {
+			return errors.New("comment action without a comment")
+		}
+	case ActionTransfer:
+		if a.TransferTo == "" {
+			return errors.New("transfer action without transfer_to")
+		}
+		if !strings.Contains(a.TransferTo, "/") {
+			a.TransferTo = a.Owner + "/" + a.TransferTo
+		}
+		if err := checkTransferTo(a.TransferTo); err != nil {
+			return err
+		}
+		if err := checkTransferOwner(a.Owner, a.TransferTo); err != nil {
+			return err
+		}
+	case ActionLock:
+		if err := checkLockReason(a.LockReason); err != nil {
+			return err
+		}
+	case ActionClose, ActionReopen, ActionUnlock, ActionDraft, ActionPin, ActionUnpin:
+	default:
+		if validActionKind(a.Kind) {
+			return fmt.Errorf("action kind %q can't be decided by a script or plugin", a.Kind)
+		}
+		return fmt.Errorf("unknown action kind %q", a.Kind)
+	}
+	return nil
+}
+
+func scriptIssue(d Directive, now time.Time, i github.Issue) *starlarkstruct.Struct {
+	labels := make([]starlark.Value, len(i.Labels))
+	for n, l := range i.Labels {
+		labels[n] = starlark.String(l.GetName())
+	}
+	return starlarkstruct.FromStringDict(starlark.String("issue"), starlark.StringDict{
+		"number":             starlark.MakeInt(i.GetNumber()),
+		"title":              starlark.String(i.GetTitle()),
+		"body":               starlark.String(i.GetBody()),
+		"state":              starlark.String(i.GetState()),
+		"author":             starlark.String(i.GetUser().GetLogin()),
+		"comments":           starlark.MakeInt(i.GetComments()),
+		"is_pr":              starlark.Bool(i.IsPullRequest()),
+		"labels":             starlark.NewList(labels),
+		"days_since_created": starlark.MakeInt(d.daysSince(now, i.GetCreatedAt())),
+		"days_since_updated": starlark.MakeInt(d.daysSince(now, i.GetUpdatedAt())),
+		"days_since_closed":  starlark.MakeInt(d.daysSince(now, i.GetClosedAt())),
+	})
+}
+
+func dictString(d *starlark.Dict, key string) string {
+	v, found, _ := d.Get(starlark.String(key))
+	if !found {
+		return ""
+	}
+	s, _ := starlark.AsString(v)
+	return s
+}
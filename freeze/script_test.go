@@ -0,0 +1,45 @@
+package freeze
+
+import "testing"
+
+func TestCheckDecidedAction(t *testing.T) {
+	cases := []struct {
+		name string
+		a    Action
+		err  bool
+	}{
+		{"label", Action{Kind: ActionLabel, Label: "stale"}, false},
+		{"label without label", Action{Kind: ActionLabel}, true},
+		{"unlabel without label", Action{Kind: ActionUnlabel}, true},
+		{"comment", Action{Kind: ActionComment, Comment: "Hi"}, false},
+		{"comment without comment", Action{Kind: ActionComment}, true},
+		{"transfer", Action{Owner: "calmh", Kind: ActionTransfer, TransferTo: "calmh/archive"}, false},
+		{"transfer owner case", Action{Owner: "calmh", Kind: ActionTransfer, TransferTo: "Calmh/archive"}, false},
+		{"transfer to other owner", Action{Owner: "calmh", Kind: ActionTransfer, TransferTo: "other/archive"}, true},
+		{"transfer without target", Action{Owner: "calmh", Kind: ActionTransfer}, true},
+		{"transfer bad target", Action{Owner: "calmh", Kind: ActionTransfer, TransferTo: "calmh/a/b"}, true},
+		{"lock", Action{Kind: ActionLock, LockReason: "resolved"}, false},
+		{"lock without reason", Action{Kind: ActionLock}, false},
+		{"lock bad reason", Action{Kind: ActionLock, LockReason: "boredom"}, true},
+		{"close", Action{Kind: ActionClose}, false},
+		{"pin", Action{Kind: ActionPin}, false},
+		{"milestone", Action{Kind: ActionMilestone}, true},
+		{"unknown", Action{Kind: "explode"}, true},
+	}
+	for _, tc := range cases {
+		a := tc.a
+		if err := checkDecidedAction(&a); (err != nil) != tc.err {
+			t.Errorf("%s: got %v, want error %v", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestCheckDecidedActionOwnerPrefix(t *testing.T) {
+	a := Action{Owner: "calmh", Kind: ActionTransfer, TransferTo: "archive"}
+	if err := checkDecidedAction(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.TransferTo != "calmh/archive" {
+		t.Errorf("got TransferTo %q, want calmh/archive", a.TransferTo)
+	}
+}
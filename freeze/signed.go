@@ -0,0 +1,58 @@
+package freeze
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// A SignedPlan is a plan along with who created it, when, and an HMAC
+// signature over all of that, so that a plan can be reviewed and then
+// executed later, knowing it hasn't been altered in between.
+type SignedPlan struct {
+	Plan      Plan
+	CreatedBy string
+	CreatedAt time.Time
+	Signature string
+}
+
+// SignPlan returns the plan signed with the given key.
+func SignPlan(plan Plan, createdBy string, createdAt time.Time, key []byte) (SignedPlan, error) {
+	sp := SignedPlan{Plan: plan, CreatedBy: createdBy, CreatedAt: createdAt.UTC()}
+	sig, err := sp.signature(key)
+	if err != nil {
+		return SignedPlan{}, err
+	}
+	sp.Signature = sig
+	return sp, nil
+}
+
+// Verify returns an error unless the plan was signed with the given key
+// and is unmodified since.
+func (sp SignedPlan) Verify(key []byte) error {
+	sig, err := sp.signature(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(sig), []byte(sp.Signature)) {
+		return errors.New("plan signature does not match")
+	}
+	return nil
+}
+
+func (sp SignedPlan) signature(key []byte) (string, error) {
+	if len(key) == 0 {
+		return "", errors.New("no signing key")
+	}
+	sp.Signature = ""
+	bs, err := json.Marshal(sp)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(bs)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
@@ -0,0 +1,47 @@
+package freeze
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignPlan(t *testing.T) {
+	key := []byte("secret")
+	plan := Plan{Actions: []Action{
+		{Owner: "calmh", Repo: "freezebot", Issue: 1, Kind: ActionClose},
+		{Owner: "calmh", Repo: "freezebot", Issue: 2, Kind: ActionLabel, Label: "stale"},
+	}}
+	createdAt := time.Date(2024, 2, 29, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	sp, err := SignPlan(plan, "jb", createdAt, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sp.Verify(key); err != nil {
+		t.Fatalf("signed plan doesn't verify: %v", err)
+	}
+	if !sp.CreatedAt.Equal(createdAt) || sp.CreatedAt.Location() != time.UTC {
+		t.Errorf("got CreatedAt %v, want %v in UTC", sp.CreatedAt, createdAt)
+	}
+
+	if err := sp.Verify([]byte("other")); err == nil {
+		t.Error("verified with the wrong key")
+	}
+	if err := sp.Verify(nil); err == nil {
+		t.Error("verified without a key")
+	}
+
+	tampered := []func(*SignedPlan){
+		func(sp *SignedPlan) { sp.CreatedBy = "mallory" },
+		func(sp *SignedPlan) { sp.CreatedAt = sp.CreatedAt.Add(time.Hour) },
+		func(sp *SignedPlan) { sp.Plan.Actions[1].Label = "wontfix" },
+		func(sp *SignedPlan) { sp.Plan.Actions = sp.Plan.Actions[:1] },
+	}
+	for n, tamper := range tampered {
+		cp := sp
+		cp.Plan.Actions = append([]Action(nil), sp.Plan.Actions...)
+		tamper(&cp)
+		if err := cp.Verify(key); err == nil {
+			t.Errorf("tampered plan %d verified", n)
+		}
+	}
+}
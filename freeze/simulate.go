@@ -0,0 +1,67 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// asOf returns the issue as it was at the runner's AsOf time, as far as we
+// can tell from its timestamps and timeline, and false if it didn't exist
+// yet.
+func (r *Runner) asOf(ctx context.Context, owner, repo string, i github.Issue) (github.Issue, bool, error) {
+	t := r.AsOf
+	if i.GetCreatedAt().After(t) {
+		return i, false, nil
+	}
+	if !i.GetUpdatedAt().After(t) {
+		// Nothing has happened since.
+		return i, true, nil
+	}
+
+	events, err := listTimeline(ctx, r.Client, owner, repo, i.GetNumber())
+	if err != nil {
+		return i, false, fmt.Errorf("timeline of issue %d: %w", i.GetNumber(), err)
+	}
+
+	updated := i.GetCreatedAt()
+	state, locked := "open", false
+	var closedAt *time.Time
+	labels := make(map[string]bool)
+	for _, ev := range events {
+		if ev.CreatedAt.After(t) {
+			continue
+		}
+		if ev.CreatedAt.After(updated) {
+			updated = ev.CreatedAt
+		}
+		switch ev.Event {
+		case "closed":
+			state = "closed"
+			at := ev.CreatedAt
+			closedAt = &at
+		case "reopened":
+			state, closedAt = "open", nil
+		case "locked":
+			locked = true
+		case "unlocked":
+			locked = false
+		case "labeled":
+			labels[ev.Label.GetName()] = true
+		case "unlabeled":
+			delete(labels, ev.Label.GetName())
+		}
+	}
+
+	i.UpdatedAt = &updated
+	i.State = github.String(state)
+	i.ClosedAt = closedAt
+	i.Locked = github.Bool(locked)
+	i.Labels = nil
+	for name := range labels {
+		i.Labels = append(i.Labels, github.Label{Name: github.String(name)})
+	}
+	return i, true, nil
+}
@@ -0,0 +1,144 @@
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// An ActionSink receives the actions decided by a run, in order. Returning
+// an error skips the remaining actions on the issue, and counts as a failed
+// action; authentication and rate limit errors stop the run.
+type ActionSink interface {
+	Act(ctx context.Context, a Action) error
+}
+
+// PrintSink writes a line describing each action, without performing it.
+type PrintSink struct {
+	W      io.Writer
+	Prefix string
+}
+
+func (s *PrintSink) Act(_ context.Context, a Action) error {
+	_, err := fmt.Fprintf(s.W, "%s%s\n", s.Prefix, a)
+	return err
+}
+
+// JSONSink writes each action as a line of JSON, without performing it.
+type JSONSink struct {
+	mut sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+func (s *JSONSink) Act(_ context.Context, a Action) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.enc.Encode(a)
+}
+
+// RecordingSink keeps the actions in memory, without performing them.
+type RecordingSink struct {
+	mut     sync.Mutex
+	Actions []Action
+}
+
+func (s *RecordingSink) Act(_ context.Context, a Action) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.Actions = append(s.Actions, a)
+	return nil
+}
+
+// failureSink logs and counts failed actions instead of passing the errors
+// on, and skips the remaining actions on issues with a failed action.
+// Errors that affect everything else as well are passed on.
+type failureSink struct {
+	next ActionSink
+
+	mut    sync.Mutex
+	failed map[string]bool // "owner/repo#number"
+}
+
+func (s *failureSink) Act(ctx context.Context, a Action) error {
+	key := closedKey(a.Owner, a.Repo, a.Issue)
+	s.mut.Lock()
+	skip := s.failed[key]
+	s.mut.Unlock()
+	if skip {
+		warnf("Not performing %s; an earlier action on the issue failed", a)
+		return nil
+	}
+
+	err := s.next.Act(ctx, a)
+	if err == nil || isFatal(err) || ctx.Err() != nil {
+		return err
+	}
+	warnf("Performing %s: %v", a, err)
+	s.mut.Lock()
+	if s.failed == nil {
+		s.failed = make(map[string]bool)
+	}
+	s.failed[key] = true
+	s.mut.Unlock()
+	return nil
+}
+
+// count returns the number of issues with a failed action.
+func (s *failureSink) count() int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return len(s.failed)
+}
+
+// stateActions are the action kinds that change the state of an issue.
+// Directives comment right before them to say why, see decideActions.
+var stateActions = map[string]bool{
+	ActionClose:      true,
+	ActionReopen:     true,
+	ActionLock:       true,
+	ActionUnlock:     true,
+	ActionTransfer:   true,
+	ActionDiscussion: true,
+}
+
+// allowedActions returns the actions for an issue that the configuration
+// allows. A comment right before a state changing action that isn't
+// allowed goes with it, as it would announce a change that doesn't happen.
+func (c Config) allowedActions(actions []Action) []Action {
+	var res []Action
+	for n, a := range actions {
+		if !c.allows(a.Kind) {
+			infof("Not performing %s; action not allowed", a)
+			continue
+		}
+		if a.Kind == ActionComment && n+1 < len(actions) {
+			if next := actions[n+1]; stateActions[next.Kind] && !c.allows(next.Kind) {
+				infof("Not performing %s; the %s it goes with is not allowed", a, next.Kind)
+				continue
+			}
+		}
+		res = append(res, a)
+	}
+	return res
+}
+
+// allowedSink drops actions that the configuration doesn't allow. Most are
+// already filtered per issue, see Config.allowedActions.
+type allowedSink struct {
+	cfg  Config
+	next ActionSink
+}
+
+func (s *allowedSink) Act(ctx context.Context, a Action) error {
+	if !s.cfg.allows(a.Kind) {
+		infof("Not performing %s; action not allowed", a)
+		return nil
+	}
+	return s.next.Act(ctx, a)
+}
@@ -0,0 +1,122 @@
+package freeze
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// A FreezeWindow is a period that doesn't count towards an issue's days of
+// inactivity, such as a holiday break.
+type FreezeWindow struct {
+	// Start and End are dates ("2006-01-02") or RFC 3339 timestamps. An
+	// End date includes the whole day.
+	Start string
+	End   string
+
+	start, end time.Time
+}
+
+func (w *FreezeWindow) validate() error {
+	var err error
+	if w.start, err = parseTime(w.Start); err != nil {
+		return err
+	}
+	if w.end, err = parseTime(w.End); err != nil {
+		return err
+	}
+	if w.start.IsZero() || w.end.IsZero() {
+		return errors.New("freeze window needs both start and end")
+	}
+	if len(w.End) == len("2006-01-02") {
+		w.end = w.end.AddDate(0, 0, 1)
+	}
+	if !w.end.After(w.start) {
+		return errors.New("freeze window ends before it starts")
+	}
+	return nil
+}
+
+// frozen returns true if t is within any of the windows.
+func frozen(windows []FreezeWindow, t time.Time) bool {
+	for _, w := range windows {
+		if !t.Before(w.start) && t.Before(w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// frozenDuration returns how much of the period between from and to is
+// within the windows. The windows are assumed not to overlap.
+func frozenDuration(windows []FreezeWindow, from, to time.Time) time.Duration {
+	var d time.Duration
+	for _, w := range windows {
+		start, end := w.start, w.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			d += end.Sub(start)
+		}
+	}
+	return d
+}
+
+// countDays returns the number of date changes between t and now, in now's
+// time zone, counting only the days for which count returns true.
+func countDays(now, t time.Time, count func(day time.Time) bool) int {
+	y, m, d := t.In(now.Location()).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	y, m, d = now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	n := 0
+	for day.Before(today) {
+		day = day.AddDate(0, 0, 1)
+		if count(day) {
+			n++
+		}
+	}
+	return n
+}
+
+func businessDay(day time.Time) bool {
+	wd := day.Weekday()
+	return wd != time.Saturday && wd != time.Sunday
+}
+
+// lastHumanActivity returns when the issue was last commented on by
+// someone other than a bot before now, or when it was created if it never
+// was.
+func lastHumanActivity(ctx context.Context, client *github.Client, owner, repo string, i github.Issue, now time.Time) (time.Time, error) {
+	last := i.GetCreatedAt()
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, repo, i.GetNumber(), opts)
+		if err != nil {
+			return time.Time{}, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			if isBot(c.GetUser()) || c.GetCreatedAt().After(now) {
+				continue
+			}
+			if c.GetCreatedAt().After(last) {
+				last = c.GetCreatedAt()
+			}
+		}
+		if resp.NextPage == 0 {
+			return last, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func isBot(u *github.User) bool {
+	return u.GetType() == "Bot" || strings.HasSuffix(u.GetLogin(), "[bot]")
+}
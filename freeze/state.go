@@ -0,0 +1,257 @@
+package freeze
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// State is persisted between runs when a state file is given.
+type State struct {
+	// Checkpoints maps a directive key (see checkpointKey) to the page
+	// number where processing should resume on the next run.
+	Checkpoints map[string]int
+	// Members caches org member and repo collaborator lists, when the
+	// runner has a MembershipTTL.
+	Members map[string]MemberList `json:",omitempty"`
+	// Closed maps "owner/repo#number" to when we closed the issue, for
+	// issues closed by a GitHubSink with this state.
+	Closed map[string]time.Time `json:",omitempty"`
+	// Locked is the same as Closed, for issues we locked.
+	Locked map[string]time.Time `json:",omitempty"`
+	// Snoozed maps "owner/repo#number" to when we may act on the issue
+	// again, after a maintainer command. The zero time means never.
+	Snoozed map[string]time.Time `json:",omitempty"`
+	// Commands maps "owner/repo#number" to the ID of the last comment
+	// checked for slash commands.
+	Commands map[string]int64 `json:",omitempty"`
+	// Graces maps "owner/repo#number" to when we gave the author of a
+	// warned pull request more time, see Directive.GraceDays.
+	Graces map[string]time.Time `json:",omitempty"`
+	// Campaigns maps the names of completed campaigns to when they were
+	// completed.
+	Campaigns map[string]time.Time `json:",omitempty"`
+	// LastRuns maps entry keys (see Entry.Key) to when they were last run
+	// in daemon mode.
+	LastRuns map[string]time.Time `json:",omitempty"`
+	// Policy is the configuration as of the last changelog, flattened (see
+	// policy).
+	Policy map[string]string `json:",omitempty"`
+
+	// mut guards the maps, which are shared by concurrently processed
+	// repos, and the saving of them.
+	mut   *sync.Mutex
+	store Store
+	// readOnly is set for the copies made by ReadOnly, of runs that
+	// don't act.
+	readOnly bool
+	// saved holds the last saved or loaded value of each section, in
+	// clear text, to skip writing unchanged ones.
+	saved  map[string][]byte
+	secret []byte
+}
+
+// sections returns the parts of the state that are stored separately, by
+// key.
+func (s *State) sections() map[string]interface{} {
+	return map[string]interface{}{
+		"Checkpoints": &s.Checkpoints,
+		"Members":     &s.Members,
+		"Closed":      &s.Closed,
+		"Locked":      &s.Locked,
+		"Snoozed":     &s.Snoozed,
+		"Commands":    &s.Commands,
+		"Graces":      &s.Graces,
+		"Campaigns":   &s.Campaigns,
+		"LastRuns":    &s.LastRuns,
+		"Policy":      &s.Policy,
+	}
+}
+
+// LoadState reads the state from the store at the given location (see
+// OpenStore). An empty location gives an empty state that isn't saved.
+func LoadState(location string) (*State, error) {
+	return LoadEncryptedState(location, nil)
+}
+
+// LoadEncryptedState is like LoadState, for a state encrypted with the
+// given secret. Unencrypted state is read as well, and encrypted when
+// saved. A nil secret means no encryption.
+func LoadEncryptedState(location string, secret []byte) (*State, error) {
+	st := &State{saved: make(map[string][]byte), secret: secret, mut: new(sync.Mutex)}
+	store, err := OpenStore(location)
+	if err != nil {
+		return nil, err
+	}
+	st.store = store
+
+	if ls, ok := store.(legacyStore); ok && ls.legacy() != nil {
+		if err := st.loadLegacy(ls.legacy()); err != nil {
+			return nil, err
+		}
+	} else if store != nil {
+		sections := st.sections()
+		err := store.Scan("", func(key string, bs []byte) error {
+			v, ok := sections[key]
+			if !ok {
+				return nil
+			}
+			sealed := isSealed(bs)
+			// Sections sealed before scrypt are sealed anew on save.
+			legacy := bytes.HasPrefix(bs, legacyEncryptedMagic)
+			if sealed {
+				if secret == nil {
+					return errors.New("state is encrypted and no key given")
+				}
+				var err error
+				bs, err = unsealState(secret, bs)
+				if err != nil {
+					return fmt.Errorf("decrypting state: %w", err)
+				}
+			}
+			if err := json.Unmarshal(bs, v); err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			if sealed == (secret != nil) && !legacy {
+				// Stored as it would be saved; no need to until
+				// changed.
+				st.saved[key], _ = json.Marshal(v)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if st.Checkpoints == nil {
+		st.Checkpoints = make(map[string]int)
+	}
+	if st.Members == nil {
+		st.Members = make(map[string]MemberList)
+	}
+	if st.Closed == nil {
+		st.Closed = make(map[string]time.Time)
+	}
+	if st.Locked == nil {
+		st.Locked = make(map[string]time.Time)
+	}
+	if st.Snoozed == nil {
+		st.Snoozed = make(map[string]time.Time)
+	}
+	if st.Commands == nil {
+		st.Commands = make(map[string]int64)
+	}
+	if st.Graces == nil {
+		st.Graces = make(map[string]time.Time)
+	}
+	if st.Campaigns == nil {
+		st.Campaigns = make(map[string]time.Time)
+	}
+	if st.LastRuns == nil {
+		st.LastRuns = make(map[string]time.Time)
+	}
+	return st, nil
+}
+
+// loadLegacy reads the state from a single, possibly encrypted, JSON blob,
+// as kept before there were stores.
+func (s *State) loadLegacy(bs []byte) error {
+	if isSealed(bs) {
+		if s.secret == nil {
+			return errors.New("state is encrypted and no key given")
+		}
+		var err error
+		bs, err = unsealState(s.secret, bs)
+		if err != nil {
+			return fmt.Errorf("decrypting state: %w", err)
+		}
+	}
+	return json.Unmarshal(bs, s)
+}
+
+// with calls fn with the state locked.
+func (s *State) with(fn func()) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	fn()
+}
+
+// repoIssues returns the entries of m, one of Closed and Locked, for
+// issues of the repo, by issue number.
+func (s *State) repoIssues(m map[string]time.Time, owner, repo string) map[int]time.Time {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	prefix := owner + "/" + repo + "#"
+	res := make(map[int]time.Time)
+	for key, t := range m {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimPrefix(key, prefix)); err == nil {
+			res[n] = t
+		}
+	}
+	return res
+}
+
+// ReadOnly returns a copy of the state that is never saved. Changes to the
+// copy don't affect the original.
+func (s *State) ReadOnly() *State {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	c := &State{saved: make(map[string][]byte), secret: s.secret, mut: new(sync.Mutex), readOnly: true}
+	dst := c.sections()
+	for key, v := range s.sections() {
+		// Sections are plain JSON data, so a round trip is a deep copy.
+		bs, _ := json.Marshal(v)
+		json.Unmarshal(bs, dst[key])
+	}
+	return c
+}
+
+// Save writes the changed sections of the state back to the store it was
+// loaded from, if any.
+func (s *State) Save() error {
+	if s == nil || s.store == nil {
+		return nil
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for key, v := range s.sections() {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if prev, ok := s.saved[key]; ok && bytes.Equal(prev, bs) {
+			continue
+		}
+		val := bs
+		if s.secret != nil {
+			val, err = sealState(s.secret, bs)
+			if err != nil {
+				return err
+			}
+		}
+		if err := s.store.Put(key, val); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		s.saved[key] = bs
+	}
+	return nil
+}
+
+// checkpointKey returns the key for a directive, scoped by campaign if
+// it's part of one.
+func checkpointKey(scope, owner, repo string, directive int) string {
+	if scope != "" {
+		return fmt.Sprintf("%s/%s/%s/%d", scope, owner, repo, directive)
+	}
+	return fmt.Sprintf("%s/%s/%d", owner, repo, directive)
+}
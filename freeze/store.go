@@ -0,0 +1,197 @@
+package freeze
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// A Store keeps named values. The state is kept in one, a value per
+// section, so that everything stateful shares the same storage.
+type Store interface {
+	// Get returns nil if there is no value for the key.
+	Get(key string) ([]byte, error)
+	Put(key string, value []byte) error
+	// Scan calls fn for each key with the given prefix, in no particular
+	// order, stopping at the first error.
+	Scan(prefix string, fn func(key string, value []byte) error) error
+}
+
+// OpenStore opens the store at the given location: a "redis://" or
+// "rediss://" URL (see newRedisStore), "sqlite:" followed by a database
+// file name, or otherwise a JSON file. An empty location gives a nil store.
+func OpenStore(location string) (Store, error) {
+	switch {
+	case location == "":
+		return nil, nil
+	case strings.HasPrefix(location, "redis://"), strings.HasPrefix(location, "rediss://"):
+		return newRedisStore(location)
+	case strings.HasPrefix(location, "sqlite:"):
+		return newSQLiteStore(strings.TrimPrefix(location, "sqlite:"))
+	default:
+		return newFileStore(location)
+	}
+}
+
+// legacyStore is implemented by stores that may hold state in the format
+// from before there were stores: a single, possibly encrypted, JSON blob.
+type legacyStore interface {
+	legacy() []byte
+}
+
+// fileStore keeps the values in a JSON object in a file, as raw JSON where
+// the value is JSON and as a string otherwise.
+type fileStore struct {
+	path string
+
+	mut    sync.Mutex
+	values map[string]json.RawMessage
+	old    []byte
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	s := &fileStore{path: path, values: make(map[string]json.RawMessage)}
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if isSealed(bs) {
+		s.old = bs
+		return s, nil
+	}
+	if err := json.Unmarshal(bs, &s.values); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fileStore) legacy() []byte {
+	return s.old
+}
+
+func (s *fileStore) Get(key string) ([]byte, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return fileValue(s.values[key])
+}
+
+// fileValue returns the stored value, undoing the string encoding of
+// non-JSON values.
+func fileValue(raw json.RawMessage) ([]byte, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var bs []byte
+		if err := json.Unmarshal(raw, &bs); err == nil {
+			return bs, nil
+		}
+	}
+	return raw, nil
+}
+
+func (s *fileStore) Put(key string, value []byte) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if json.Valid(value) && (len(value) == 0 || value[0] != '"') {
+		s.values[key] = value
+	} else {
+		bs, _ := json.Marshal(value)
+		s.values[key] = bs
+	}
+	s.old = nil
+
+	bs, err := json.MarshalIndent(s.values, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, bs, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *fileStore) Scan(prefix string, fn func(key string, value []byte) error) error {
+	s.mut.Lock()
+	values := make(map[string]json.RawMessage, len(s.values))
+	for k, v := range s.values {
+		values[k] = v
+	}
+	s.mut.Unlock()
+
+	for k, v := range values {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		bs, err := fileValue(v)
+		if err != nil {
+			return err
+		}
+		if err := fn(k, bs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteStore keeps the values in a table of an SQLite database.
+type sqliteStore struct {
+	db *sql.DB
+}
+
+func newSQLiteStore(path string) (*sqliteStore, error) {
+	if path == "" {
+		return nil, errors.New("no SQLite database file given")
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS freezebot (key TEXT PRIMARY KEY, value BLOB NOT NULL)`); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &sqliteStore{db: db}, nil
+}
+
+func (s *sqliteStore) Get(key string) ([]byte, error) {
+	var bs []byte
+	err := s.db.QueryRow(`SELECT value FROM freezebot WHERE key = ?`, key).Scan(&bs)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return bs, err
+}
+
+func (s *sqliteStore) Put(key string, value []byte) error {
+	_, err := s.db.Exec(`INSERT INTO freezebot (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`, key, value)
+	return err
+}
+
+func (s *sqliteStore) Scan(prefix string, fn func(key string, value []byte) error) error {
+	rows, err := s.db.Query(`SELECT key, value FROM freezebot WHERE substr(key, 1, ?) = ?`, len(prefix), prefix)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,157 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// summaryTarget is a parsed "owner/repo#number" reference to the issue
+// that gets the summary comment of a directive.
+type summaryTarget struct {
+	owner, repo string
+	number      int
+}
+
+func parseIssueRef(s string) (summaryTarget, error) {
+	repo, num, ok := strings.Cut(s, "#")
+	owner, name, ok2 := strings.Cut(repo, "/")
+	n, err := strconv.Atoi(num)
+	if !ok || !ok2 || owner == "" || name == "" || err != nil || n <= 0 {
+		return summaryTarget{}, fmt.Errorf("%q is not \"owner/repo#number\"", s)
+	}
+	return summaryTarget{owner, name, n}, nil
+}
+
+func (t summaryTarget) String() string {
+	return fmt.Sprintf("%s/%s#%d", t.owner, t.repo, t.number)
+}
+
+// summaries collects the issues closed by directives with a SummaryIssue
+// during a run.
+type summaries struct {
+	mut    sync.Mutex
+	order  []summaryTarget
+	closed map[summaryTarget][]string
+}
+
+// A SummaryLine is an issue to list in the summary comment on the issue
+// Owner/Repo#Issue, once closed.
+type SummaryLine struct {
+	Owner string
+	Repo  string
+	Issue int
+	Line  string
+}
+
+func newSummaryLine(t summaryTarget, owner, repo string, i github.Issue) *SummaryLine {
+	return &SummaryLine{t.owner, t.repo, t.number, fmt.Sprintf("- [%s/%s#%d](%s) %s", owner, repo, i.GetNumber(), i.GetHTMLURL(), i.GetTitle())}
+}
+
+func (s *summaries) add(l SummaryLine) {
+	t := summaryTarget{l.Owner, l.Repo, l.Issue}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if s.closed == nil {
+		s.closed = make(map[summaryTarget][]string)
+	}
+	if _, ok := s.closed[t]; !ok {
+		s.order = append(s.order, t)
+	}
+	s.closed[t] = append(s.closed[t], l.Line)
+}
+
+// summarySink adds the issues of close actions with a summary line to the
+// summaries, once they have been closed.
+type summarySink struct {
+	summaries *summaries
+	next      ActionSink
+}
+
+func (s *summarySink) Act(ctx context.Context, a Action) error {
+	if err := s.next.Act(ctx, a); err != nil {
+		return err
+	}
+	if a.Summary != nil {
+		s.summaries.add(*a.Summary)
+	}
+	return nil
+}
+
+func (s *summaries) reset() {
+	s.mut.Lock()
+	s.order, s.closed = nil, nil
+	s.mut.Unlock()
+}
+
+// flush passes a comment action for each summary issue to the sink, and
+// resets the collection.
+func (s *summaries) flush(ctx context.Context, sink ActionSink, now string) error {
+	s.mut.Lock()
+	order, closed := s.order, s.closed
+	s.mut.Unlock()
+	s.reset()
+
+	for _, t := range order {
+		lines := closed[t]
+		parts := splitLines(lines, maxCommentLength-summaryHeaderSlack)
+		for n, part := range parts {
+			header := fmt.Sprintf("Closed %d issues on %s", len(lines), now)
+			if len(parts) > 1 {
+				header += fmt.Sprintf(" (part %d of %d)", n+1, len(parts))
+			}
+			a := Action{
+				Owner:     t.owner,
+				Repo:      t.repo,
+				Issue:     t.number,
+				Directive: summaryDirective,
+				Kind:      ActionComment,
+				Comment:   fmt.Sprintf("%s:\n\n%s\n", header, strings.Join(part, "\n")),
+			}
+			if err := sink.Act(ctx, a); err != nil {
+				return fmt.Errorf("posting summary to %s: %w", t, err)
+			}
+		}
+	}
+	return nil
+}
+
+// summaryDirective is the directive name of summary comments. They are
+// made from the close actions at the end of a run, or of applying a plan.
+const summaryDirective = "summary"
+
+// maxCommentLength is the longest comment GitHub accepts, in characters.
+// summaryHeaderSlack leaves room for the header of a summary comment.
+const (
+	maxCommentLength   = 65536
+	summaryHeaderSlack = 200
+)
+
+// splitLines groups the lines so that each group, joined by newlines, is
+// at most max characters. A single longer line gets a group of its own,
+// cut to length.
+func splitLines(lines []string, max int) [][]string {
+	var parts [][]string
+	var cur []string
+	size := 0
+	for _, l := range lines {
+		if r := []rune(l); len(r) > max {
+			l = string(r[:max])
+		}
+		n := len([]rune(l)) + 1
+		if len(cur) > 0 && size+n > max {
+			parts = append(parts, cur)
+			cur, size = nil, 0
+		}
+		cur = append(cur, l)
+		size += n
+	}
+	if len(cur) > 0 {
+		parts = append(parts, cur)
+	}
+	return parts
+}
@@ -0,0 +1,68 @@
+package freeze
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	line := func(n int) string { return strings.Repeat("x", n) }
+	cases := []struct {
+		name  string
+		lines []string
+		max   int
+		sizes []int // lines per part
+	}{
+		{"none", nil, 10, nil},
+		{"one part", []string{line(3), line(3)}, 10, []int{2}},
+		// Each line takes its length plus a newline.
+		{"exact fit", []string{line(4), line(4)}, 10, []int{2}},
+		{"split", []string{line(4), line(4), line(4)}, 10, []int{2, 1}},
+		{"long line alone", []string{line(2), line(20), line(2)}, 10, []int{1, 1, 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			parts := splitLines(tc.lines, tc.max)
+			if len(parts) != len(tc.sizes) {
+				t.Fatalf("got %d parts, want %d", len(parts), len(tc.sizes))
+			}
+			for n, p := range parts {
+				if len(p) != tc.sizes[n] {
+					t.Errorf("part %d has %d lines, want %d", n, len(p), tc.sizes[n])
+				}
+				if joined := strings.Join(p, "\n"); len(joined) > tc.max {
+					t.Errorf("part %d is %d characters, more than %d", n, len(joined), tc.max)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitLinesCountsRunes(t *testing.T) {
+	lines := []string{strings.Repeat("å", 6), strings.Repeat("ä", 12)}
+	parts := splitLines(lines, 10)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	if got := []rune(parts[1][0]); len(got) != 10 {
+		t.Errorf("long line cut to %d runes, want 10", len(got))
+	}
+}
+
+func TestParseIssueRef(t *testing.T) {
+	good := map[string]summaryTarget{
+		"calmh/freezebot#12": {"calmh", "freezebot", 12},
+		"a/b#1":              {"a", "b", 1},
+	}
+	for s, want := range good {
+		got, err := parseIssueRef(s)
+		if err != nil || got != want {
+			t.Errorf("parseIssueRef(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "a/b", "a#1", "/b#1", "a/#1", "a/b#0", "a/b#x", "a/b#-1"} {
+		if _, err := parseIssueRef(s); err == nil {
+			t.Errorf("parseIssueRef(%q) succeeded", s)
+		}
+	}
+}
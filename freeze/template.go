@@ -0,0 +1,133 @@
+package freeze
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// commentData is what comment templates get to see.
+type commentData struct {
+	Repo *repoData
+
+	// The issue being commented on.
+	Number    int
+	Title     string
+	URL       string
+	Author    string
+	Labels    []string
+	Assignees []string
+	// Days since the issue was created, updated and closed (zero if open),
+	// counted as for the directive's thresholds.
+	DaysSinceCreate int
+	DaysSinceUpdate int
+	DaysSinceClose  int
+	// Directive is the name of the directive acting on the issue.
+	Directive string
+	// Comments are the most recent comments, for lock summaries.
+	Comments []commentInfo `json:",omitempty"`
+
+	// Version and MinVersion are set for EOL comments.
+	Version    string
+	MinVersion string
+}
+
+type repoData struct {
+	Owner         string
+	Name          string
+	FullName      string
+	HTMLURL       string
+	DefaultBranch string
+	// LatestRelease is the tag name of the latest release, if any.
+	LatestRelease string
+}
+
+// repoCache holds repo metadata for the duration of a run.
+type repoCache struct {
+	mut   sync.Mutex
+	repos map[string]*repoData
+}
+
+func (c *repoCache) get(ctx context.Context, client *github.Client, owner, repo string) (*repoData, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	key := owner + "/" + repo
+	if rd, ok := c.repos[key]; ok {
+		return rd, nil
+	}
+
+	r, _, err := client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, classifyAPIError(err)
+	}
+	rd := &repoData{
+		Owner:         owner,
+		Name:          repo,
+		FullName:      r.GetFullName(),
+		HTMLURL:       r.GetHTMLURL(),
+		DefaultBranch: r.GetDefaultBranch(),
+	}
+
+	rel, resp, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return nil, classifyAPIError(err)
+	}
+	rd.LatestRelease = rel.GetTagName()
+
+	if c.repos == nil {
+		c.repos = make(map[string]*repoData)
+	}
+	c.repos[key] = rd
+	return rd, nil
+}
+
+// issueCommentData returns the comment data for the issue.
+func issueCommentData(d Directive, now time.Time, i github.Issue) commentData {
+	data := commentData{
+		Number:          i.GetNumber(),
+		Title:           i.GetTitle(),
+		URL:             i.GetHTMLURL(),
+		Author:          i.GetUser().GetLogin(),
+		DaysSinceCreate: d.daysSince(now, i.GetCreatedAt()),
+		DaysSinceUpdate: d.daysSince(now, i.GetUpdatedAt()),
+		Directive:       d.Name,
+	}
+	if !i.GetClosedAt().IsZero() {
+		data.DaysSinceClose = d.daysSince(now, i.GetClosedAt())
+	}
+	for _, l := range i.Labels {
+		data.Labels = append(data.Labels, l.GetName())
+	}
+	for _, u := range i.Assignees {
+		data.Assignees = append(data.Assignees, u.GetLogin())
+	}
+	return data
+}
+
+func parseCommentTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(text)
+}
+
+// renderComment executes the comment template. Repo metadata is only
+// looked up when the template looks like it needs it.
+func (r *Runner) renderComment(ctx context.Context, tmpl *template.Template, owner, repo string, data commentData) (string, error) {
+	if strings.Contains(tmpl.Root.String(), ".Repo") {
+		rd, err := r.repos.get(ctx, r.Client, owner, repo)
+		if err != nil {
+			return "", err
+		}
+		data.Repo = rd
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
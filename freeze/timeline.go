@@ -0,0 +1,185 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// timelineEvent is an issue timeline event, as far as we care about it. The
+// client library's version lacks the source issue of cross references.
+type timelineEvent struct {
+	Event     string
+	CreatedAt time.Time `json:"created_at"`
+	Actor     *github.User
+	Label     *github.Label
+	Source    *struct {
+		Type  string
+		Issue *github.Issue
+	}
+}
+
+func listTimeline(ctx context.Context, client *github.Client, owner, repo string, number int) ([]timelineEvent, error) {
+	var res []timelineEvent
+	opts := &github.ListOptions{PerPage: perPage}
+	for {
+		u, err := addPageOptions(fmt.Sprintf("repos/%v/%v/issues/%d/timeline", owner, repo, number), opts)
+		if err != nil {
+			return nil, err
+		}
+		req, err := client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github.mockingbird-preview+json")
+
+		var evs []timelineEvent
+		resp, err := client.Do(ctx, req, &evs)
+		if err != nil {
+			return nil, classifyAPIError(err)
+		}
+		res = append(res, evs...)
+
+		if resp.NextPage == 0 {
+			return res, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func addPageOptions(u string, opts *github.ListOptions) (string, error) {
+	if opts.Page == 0 {
+		return fmt.Sprintf("%s?per_page=%d", u, opts.PerPage), nil
+	}
+	return fmt.Sprintf("%s?per_page=%d&page=%d", u, opts.PerPage, opts.Page), nil
+}
+
+var uncheckedTaskRe = regexp.MustCompile(`(?m)^\s*[-*+]\s+\[ \]\s+(.*)$`)
+
+// lastEvent returns the time of the last timeline event of the issue that
+// the function matches, or the zero time if there is none.
+func lastEvent(ctx context.Context, client *github.Client, owner, repo string, number int, match func(timelineEvent) bool) (time.Time, error) {
+	evs, err := listTimeline(ctx, client, owner, repo, number)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	for _, ev := range evs {
+		if match(ev) && ev.CreatedAt.After(t) {
+			t = ev.CreatedAt
+		}
+	}
+	return t, nil
+}
+
+// needsInfoSince returns when the label was last added to the issue, or
+// when the author last commented after that. The issue's update time is
+// used if the label event isn't found.
+func (r *Runner) needsInfoSince(ctx context.Context, owner, repo string, i github.Issue, label string) (time.Time, error) {
+	evs, err := listTimeline(ctx, r.Client, owner, repo, i.GetNumber())
+	if err != nil {
+		return time.Time{}, err
+	}
+	var labeled time.Time
+	for _, ev := range evs {
+		if ev.Event == "labeled" && ev.Label != nil && ev.Label.GetName() == label && ev.CreatedAt.After(labeled) {
+			labeled = ev.CreatedAt
+		}
+	}
+	if labeled.IsZero() {
+		return i.GetUpdatedAt(), nil
+	}
+	since := labeled
+	for _, ev := range evs {
+		if ev.Event == "commented" && ev.Actor.GetLogin() == i.GetUser().GetLogin() && ev.CreatedAt.After(since) {
+			since = ev.CreatedAt
+		}
+	}
+	return since, nil
+}
+
+// closedByBot returns true if we closed the issue, per the state, or else
+// the timeline says a bot did.
+func (r *Runner) closedByBot(ctx context.Context, owner, repo string, i github.Issue) (bool, error) {
+	var ours bool
+	r.State.with(func() { _, ours = r.State.Closed[closedKey(owner, repo, i.GetNumber())] })
+	if ours {
+		return true, nil
+	}
+	evs, err := listTimeline(ctx, r.Client, owner, repo, i.GetNumber())
+	if err != nil {
+		return false, classifyAPIError(err)
+	}
+	var last timelineEvent
+	for _, ev := range evs {
+		if ev.Event == "closed" && !ev.CreatedAt.Before(last.CreatedAt) {
+			last = ev
+		}
+	}
+	return last.Actor != nil && isBot(last.Actor), nil
+}
+
+// trackingIssue returns the full name ("owner/repo#number") of an open
+// issue that refers to the given one as an unchecked task, or the empty
+// string if there is none.
+func trackingIssue(ctx context.Context, client *github.Client, owner, repo string, number int) (string, error) {
+	evs, err := listTimeline(ctx, client, owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ev := range evs {
+		if ev.Event != "cross-referenced" || ev.Source == nil || ev.Source.Issue == nil {
+			continue
+		}
+		src := ev.Source.Issue
+		if src.GetState() != "open" {
+			continue
+		}
+		srcRepo := src.GetRepository().GetFullName()
+		sameRepo := strings.EqualFold(srcRepo, owner+"/"+repo)
+		for _, m := range uncheckedTaskRe.FindAllStringSubmatch(src.GetBody(), -1) {
+			if refersTo(m[1], owner, repo, number, sameRepo) {
+				return fmt.Sprintf("%s#%d", srcRepo, src.GetNumber()), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// externalReference returns the full name ("owner/repo#number") of an open
+// issue or pull request in another repo that referenced the given issue
+// since the given time, or the empty string if there is none.
+func externalReference(ctx context.Context, client *github.Client, owner, repo string, number int, since time.Time) (string, error) {
+	evs, err := listTimeline(ctx, client, owner, repo, number)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ev := range evs {
+		if ev.Event != "cross-referenced" || ev.Source == nil || ev.Source.Issue == nil || ev.CreatedAt.Before(since) {
+			continue
+		}
+		src := ev.Source.Issue
+		srcRepo := src.GetRepository().GetFullName()
+		if src.GetState() == "open" && !strings.EqualFold(srcRepo, owner+"/"+repo) {
+			return fmt.Sprintf("%s#%d", srcRepo, src.GetNumber()), nil
+		}
+	}
+	return "", nil
+}
+
+// refersTo returns true if the text contains a reference to the issue, as
+// "owner/repo#number", an issue URL, or "#number" when in the same repo.
+func refersTo(text, owner, repo string, number int, sameRepo bool) bool {
+	full := regexp.QuoteMeta(owner + "/" + repo)
+	pat := fmt.Sprintf(`(?i)(%s#|github\.com/%s/(issues|pull)/)%d\b`, full, full, number)
+	if sameRepo {
+		pat = fmt.Sprintf(`(?i)((^|[^\w/])#|%s#|github\.com/%s/(issues|pull)/)%d\b`, full, full, number)
+	}
+	return regexp.MustCompile(pat).MatchString(text)
+}
@@ -0,0 +1,22 @@
+package freeze
+
+import (
+	"encoding/json"
+
+	"github.com/BurntSushi/toml"
+)
+
+// ParseTOMLConfig parses and validates a TOML configuration, with the same
+// schema as the JSON one.
+func ParseTOMLConfig(bs []byte) (Config, error) {
+	var v map[string]interface{}
+	if err := toml.Unmarshal(bs, &v); err != nil {
+		return Config{}, &ConfigError{err}
+	}
+	// Go through JSON so that field names are matched the same way.
+	js, err := json.Marshal(v)
+	if err != nil {
+		return Config{}, &ConfigError{err}
+	}
+	return ParseConfig(js)
+}
@@ -0,0 +1,36 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// unlockExpired unlocks the issues of the repo that the state says we
+// locked at least UnlockAfterDays ago, treating the lock as a cooling off
+// period.
+func (r *Runner) unlockExpired(ctx context.Context, owner, repo string, directive Directive, sink ActionSink) error {
+	now := r.Clock.Now()
+	for number, lockedAt := range r.State.repoIssues(r.State.Locked, owner, repo) {
+		if !directive.reached(now, lockedAt, directive.UnlockAfterDays) {
+			continue
+		}
+
+		i, _, err := r.Client.Issues.Get(ctx, owner, repo, number)
+		if err != nil {
+			return fmt.Errorf("getting issue %d: %w", number, classifyAPIError(err))
+		}
+		if !i.GetLocked() {
+			// Someone beat us to it.
+			r.State.with(func() { delete(r.State.Locked, closedKey(owner, repo, number)) })
+			continue
+		}
+
+		debugf("Issue %d was locked %v ago", number, now.Sub(lockedAt).Truncate(time.Hour))
+		a := Action{Owner: owner, Repo: repo, Issue: number, Directive: directive.Name, Kind: ActionUnlock, IssueUpdatedAt: i.GetUpdatedAt(), IssueNodeID: i.GetNodeID()}
+		if err := sink.Act(ctx, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
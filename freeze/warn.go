@@ -0,0 +1,104 @@
+package freeze
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// warnSlack is how long after the label is added that updates to the issue
+// are taken to be part of the warning, rather than activity after it.
+const warnSlack = time.Minute
+
+// warned returns true if the directive warns before acting and the issue
+// carries the warning label.
+func (d Directive) warned(i github.Issue) bool {
+	return d.warnComment != nil && contains(i.Labels, d.Label)
+}
+
+// warnActions returns the actions for the warning stage of a directive with
+// a WarnComment, and whether the issue is past that stage so that the
+// directive's other actions should be taken.
+func (r *Runner) warnActions(ctx context.Context, base Action, i github.Issue, directive Directive) ([]Action, bool, error) {
+	if !directive.warned(i) {
+		text, err := r.renderComment(ctx, directive.warnComment, base.Owner, base.Repo, issueCommentData(directive, r.Clock.Now(), i))
+		if err != nil {
+			return nil, false, err
+		}
+		comment, label := base, base
+		comment.Kind, comment.Comment, comment.Reaction = ActionComment, directive.comment(text), directive.CommentReaction
+		label.Kind, label.Label = ActionLabel, directive.Label
+		return []Action{comment, label}, false, nil
+	}
+
+	warned, err := lastEvent(ctx, r.Client, base.Owner, base.Repo, i.GetNumber(), func(ev timelineEvent) bool {
+		return ev.Event == "labeled" && ev.Label.GetName() == directive.Label
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	if warned.IsZero() {
+		// No labeling event, as for some transferred issues; count from
+		// the last update.
+		warned = i.GetUpdatedAt()
+	}
+	days := directive.DaysAfterWarn
+	if directive.GraceDays > 0 && i.IsPullRequest() {
+		graced, err := r.graced(ctx, base, i, warned)
+		if err != nil {
+			return nil, false, err
+		}
+		if graced {
+			days += directive.GraceDays
+			if !directive.reached(r.Clock.Now(), warned, days) {
+				return nil, false, nil
+			}
+			r.State.with(func() { delete(r.State.Graces, closedKey(base.Owner, base.Repo, base.Issue)) })
+			return nil, true, nil
+		}
+	}
+	if i.GetUpdatedAt().After(warned.Add(warnSlack)) {
+		debugf("Issue %d has activity since the warning", i.GetNumber())
+		unlabel := base
+		unlabel.Kind, unlabel.Label = ActionUnlabel, directive.Label
+		return []Action{unlabel}, false, nil
+	}
+	return nil, directive.reached(r.Clock.Now(), warned, days), nil
+}
+
+// graced returns true if the author of the pull request has replied to the
+// warning given at the given time, now or in an earlier run. Replies found
+// are recorded in the state.
+func (r *Runner) graced(ctx context.Context, base Action, i github.Issue, warned time.Time) (bool, error) {
+	key := closedKey(base.Owner, base.Repo, base.Issue)
+	var ok bool
+	r.State.with(func() { _, ok = r.State.Graces[key] })
+	if ok {
+		return true, nil
+	}
+	if !i.GetUpdatedAt().After(warned.Add(warnSlack)) {
+		return false, nil
+	}
+
+	author := i.GetUser().GetLogin()
+	opts := &github.IssueListCommentsOptions{Since: warned, ListOptions: github.ListOptions{PerPage: perPage}}
+	for {
+		comments, resp, err := r.Client.Issues.ListComments(ctx, base.Owner, base.Repo, base.Issue, opts)
+		if err != nil {
+			return false, classifyAPIError(err)
+		}
+		for _, c := range comments {
+			if c.GetCreatedAt().After(warned) && strings.EqualFold(c.GetUser().GetLogin(), author) {
+				infof("Author of pull request %d replied to the warning; giving them more time", base.Issue)
+				r.State.with(func() { r.State.Graces[key] = r.Clock.Now() })
+				return true, nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,146 @@
+package freeze
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// HandleIssue evaluates the directives of the configuration that apply to
+// the repo against a single issue, as when notified of a change to it,
+// and passes the resulting actions to the sink. Directives that search by
+// query, unlock or resurrect issues are left to regular runs, as they
+// can't be evaluated for a single issue. Each call counts as a run as far
+// as the action caps and failed actions are concerned.
+func (r *Runner) HandleIssue(ctx context.Context, cfg Config, owner, repo string, number int) error {
+	if r.Clock == nil {
+		r.Clock = RealClock{}
+	}
+	if r.State == nil {
+		r.State, _ = LoadState("")
+	}
+	var sink ActionSink = &summarySink{summaries: &r.summaries, next: r.sink()}
+	if len(cfg.AllowedActions) > 0 {
+		sink = &allowedSink{cfg: cfg, next: sink}
+	}
+	failures := &failureSink{next: sink}
+	sink = failures
+	r.allowed = Config{AllowedActions: cfg.AllowedActions}
+	r.caps.reset(cfg.MaxActions)
+
+	var directives []Directive
+	for _, e := range cfg.Entries {
+		if e.covers(owner, repo) {
+			directives = append(directives, e.Directives...)
+		}
+	}
+	for _, c := range cfg.Campaigns {
+		if c.covers(owner, repo) && c.active(r.Clock.Now(), r.State) {
+			directives = append(directives, c.Directives...)
+		}
+	}
+	if len(directives) == 0 {
+		return nil
+	}
+
+	issue, _, err := r.Client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("getting %s/%s#%d: %w", owner, repo, number, classifyAPIError(err))
+	}
+	i := *issue
+
+	r.summaries.reset()
+	for _, directive := range directives {
+		if directive.Query != "" || directive.UnlockAfterDays > 0 || directive.Resurrect != nil {
+			continue
+		}
+		if !directive.searchedIn(repo) {
+			continue
+		}
+		if r.caps.full(directive) {
+			infof("Skipping directive %s for %s/%s; action cap reached", directive.Name, owner, repo)
+			continue
+		}
+		state := directive.State
+		if state == "" {
+			state = "open"
+		}
+		if state != "all" && i.GetState() != state {
+			continue
+		}
+		ok, err := r.matchesActivity(ctx, owner, repo, i, directive)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		actions, err := r.decide(ctx, owner, repo, i, directive)
+		if err != nil {
+			return err
+		}
+		if len(actions) > 0 && !r.caps.take(directive, len(actions)) {
+			infof("Action cap reached for directive %s in %s/%s; the issue is left for the next run", directive.Name, owner, repo)
+			continue
+		}
+		for _, a := range actions {
+			if err := sink.Act(ctx, a); err != nil {
+				return err
+			}
+		}
+		// Later directives see the issue as we left it.
+		applyLocally(&i, actions, r.Clock.Now())
+	}
+	if err := r.summaries.flush(ctx, sink, r.Clock.Now().Format("2006-01-02")); err != nil {
+		return err
+	}
+	if err := r.State.Save(); err != nil {
+		return err
+	}
+	if failures.count() > 0 {
+		return &PartialFailureError{FailedActions: failures.count()}
+	}
+	return nil
+}
+
+// covers returns true if the entry processes the given repo.
+func (e Entry) covers(owner, repo string) bool {
+	if e.Owner != owner {
+		return false
+	}
+	if len(e.Repos) == 0 {
+		return true
+	}
+	for _, r := range e.Repos {
+		if r == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueEvent returns the repo and issue number a webhook payload of the
+// given type is about, if it's an issue or issue comment event not caused
+// by a bot.
+func IssueEvent(eventType string, payload []byte) (owner, repo string, number int, ok bool) {
+	ev, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		return "", "", 0, false
+	}
+	var r *github.Repository
+	var i *github.Issue
+	var sender *github.User
+	switch ev := ev.(type) {
+	case *github.IssuesEvent:
+		r, i, sender = ev.Repo, ev.Issue, ev.Sender
+	case *github.IssueCommentEvent:
+		r, i, sender = ev.Repo, ev.Issue, ev.Sender
+	default:
+		return "", "", 0, false
+	}
+	if r == nil || i == nil || isBot(sender) {
+		return "", "", 0, false
+	}
+	return r.GetOwner().GetLogin(), r.GetName(), i.GetNumber(), true
+}
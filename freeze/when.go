@@ -0,0 +1,101 @@
+package freeze
+
+import (
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"github.com/google/go-github/github"
+)
+
+// eventFuncs are the When functions that need the issue's timeline.
+var eventFuncs = map[string]bool{
+	"hasEvent":  true,
+	"lastEvent": true,
+	"lastActor": true,
+	"labeledAt": true,
+}
+
+// compileWhen compiles a directive's When expression, checking it against
+// the environment it will be evaluated in.
+func compileWhen(src string) (*vm.Program, error) {
+	return expr.Compile(src, expr.Env(whenEnv(Directive{}, time.Time{}, github.Issue{}, nil)), expr.AsBool())
+}
+
+// usesEvents returns true if the program calls any of the event functions.
+func usesEvents(prog *vm.Program) bool {
+	v := &eventVisitor{}
+	node := prog.Node()
+	ast.Walk(&node, v)
+	return v.found
+}
+
+type eventVisitor struct{ found bool }
+
+func (v *eventVisitor) Visit(node *ast.Node) {
+	if id, ok := (*node).(*ast.IdentifierNode); ok && eventFuncs[id.Value] {
+		v.found = true
+	}
+}
+
+// whenEnv is what a When expression gets to see of the issue. The event
+// functions look at the issue's timeline: hasEvent("closed"),
+// lastEvent("assigned") for when it last happened (the zero time if never),
+// lastActor("closed") for who did it, and labeledAt("bug") for when the
+// label was last added.
+func whenEnv(d Directive, now time.Time, i github.Issue, evs []timelineEvent) map[string]interface{} {
+	labels := make([]string, len(i.Labels))
+	for n, l := range i.Labels {
+		labels[n] = l.GetName()
+	}
+	last := func(match func(timelineEvent) bool) (ev timelineEvent) {
+		for _, e := range evs {
+			if match(e) && !e.CreatedAt.Before(ev.CreatedAt) {
+				ev = e
+			}
+		}
+		return ev
+	}
+	return map[string]interface{}{
+		"number":   i.GetNumber(),
+		"title":    i.GetTitle(),
+		"state":    i.GetState(),
+		"author":   i.GetUser().GetLogin(),
+		"comments": i.GetComments(),
+		"isPR":     i.IsPullRequest(),
+		"labels":   labels,
+		"created":  i.GetCreatedAt(),
+		"updated":  i.GetUpdatedAt(),
+		"closed":   i.GetClosedAt(),
+		"now":      now,
+		"daysSince": func(t time.Time) int {
+			return d.daysSince(now, t)
+		},
+		"hasLabel": func(name string) bool {
+			return contains(i.Labels, name)
+		},
+		"hasEvent": func(kind string) bool {
+			return !last(func(e timelineEvent) bool { return e.Event == kind }).CreatedAt.IsZero()
+		},
+		"lastEvent": func(kind string) time.Time {
+			return last(func(e timelineEvent) bool { return e.Event == kind }).CreatedAt
+		},
+		"lastActor": func(kind string) string {
+			return last(func(e timelineEvent) bool { return e.Event == kind }).Actor.GetLogin()
+		},
+		"labeledAt": func(name string) time.Time {
+			return last(func(e timelineEvent) bool {
+				return e.Event == "labeled" && e.Label != nil && e.Label.GetName() == name
+			}).CreatedAt
+		},
+	}
+}
+
+func (d Directive) evalWhen(now time.Time, i github.Issue, evs []timelineEvent) (bool, error) {
+	res, err := expr.Run(d.when, whenEnv(d, now, i, evs))
+	if err != nil {
+		return false, err
+	}
+	return res.(bool), nil
+}
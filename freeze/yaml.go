@@ -0,0 +1,22 @@
+package freeze
+
+import (
+	"encoding/json"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseYAMLConfig parses and validates a YAML configuration, with the same
+// schema as the JSON one.
+func ParseYAMLConfig(bs []byte) (Config, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(bs, &v); err != nil {
+		return Config{}, &ConfigError{err}
+	}
+	// Go through JSON so that field names are matched the same way.
+	js, err := json.Marshal(v)
+	if err != nil {
+		return Config{}, &ConfigError{err}
+	}
+	return ParseConfig(js)
+}
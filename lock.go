@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"calmh.dev/freezebot/freeze"
+	"github.com/google/go-github/github"
+)
+
+// lockRef is the ref holding the lock in a repo, for "github:" locks.
+const lockRef = "refs/freezebot/lock"
+
+// errLocked is returned when another instance holds the lock.
+var errLocked = errors.New("another instance holds the lock")
+
+// acquireLock takes the run lock described by spec, which is
+// "file:/path/to/lockfile" or "github:owner/repo", returning the function
+// that releases it. Locks older than ttl are considered abandoned and are
+// taken over, so the lock is refreshed every third of ttl while held.
+func acquireLock(ctx context.Context, client *github.Client, spec string, ttl time.Duration) (func() error, error) {
+	kind, arg, ok := strings.Cut(spec, ":")
+	if !ok || arg == "" {
+		return nil, &freeze.ConfigError{Err: fmt.Errorf("bad lock %q; expected file:PATH or github:OWNER/REPO", spec)}
+	}
+	var l runLock
+	switch kind {
+	case "file":
+		l = &fileLock{path: arg}
+	case "github":
+		owner, repo, ok := strings.Cut(arg, "/")
+		if !ok {
+			return nil, &freeze.ConfigError{Err: fmt.Errorf("bad lock repo %q", arg)}
+		}
+		l = &githubLock{client: client, owner: owner, repo: repo}
+	default:
+		return nil, &freeze.ConfigError{Err: fmt.Errorf("unknown lock kind %q", kind)}
+	}
+	if err := l.acquire(ctx, ttl); err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		return l.release, nil
+	}
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(ttl / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				if err := l.refresh(ctx); err != nil {
+					log.Println("Refreshing lock:", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() error {
+		close(stop)
+		<-done
+		return l.release()
+	}, nil
+}
+
+// A runLock is a lock held with a holder string (see lockHolder) whose
+// time must be refreshed to keep it from being taken over.
+type runLock interface {
+	acquire(ctx context.Context, ttl time.Duration) error
+	refresh(ctx context.Context) error
+	release() error
+}
+
+// lockID identifies us, for the benefit of whoever finds the lock and for
+// telling our own lock from others'.
+func lockID() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s pid %d", host, os.Getpid())
+}
+
+// lockHolder is what we put in the lock: our ID and the current time.
+func lockHolder() string {
+	return fmt.Sprintf("%s at %s", lockID(), time.Now().UTC().Format(time.RFC3339))
+}
+
+// ours returns true if the holder string is ours.
+func ours(holder string) bool {
+	return strings.HasPrefix(strings.TrimSpace(holder), lockID()+" at ")
+}
+
+// lockTime returns the time in a lock holder string.
+func lockTime(holder string) time.Time {
+	_, ts, _ := strings.Cut(strings.TrimSpace(holder), " at ")
+	t, _ := time.Parse(time.RFC3339, ts)
+	return t
+}
+
+// fileLock is a lock file, created exclusively. Abandoned lock files are
+// renamed away, so that of two instances taking over the same one only
+// one succeeds, and refreshed by renaming a new file into place.
+type fileLock struct {
+	path string
+}
+
+func (l *fileLock) acquire(_ context.Context, ttl time.Duration) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		fd, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = fd.WriteString(lockHolder() + "\n")
+			if cerr := fd.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(l.path)
+				return err
+			}
+			return nil
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+
+		bs, _ := ioutil.ReadFile(l.path)
+		held := strings.TrimSpace(string(bs))
+		if t := lockTime(held); t.IsZero() || time.Since(t) < ttl {
+			return fmt.Errorf("%w: %s (%s)", errLocked, held, l.path)
+		}
+		log.Printf("Taking over abandoned lock %s held by %s", l.path, held)
+		stale := fmt.Sprintf("%s.stale.%d", l.path, os.Getpid())
+		if err := os.Rename(l.path, stale); err != nil {
+			// Someone else got to it first.
+			continue
+		}
+		bs, _ = ioutil.ReadFile(stale)
+		if strings.TrimSpace(string(bs)) != held {
+			// Someone else took it over between our read and rename;
+			// put their lock back.
+			os.Rename(stale, l.path)
+			return fmt.Errorf("%w: %s", errLocked, l.path)
+		}
+		os.Remove(stale)
+	}
+	return fmt.Errorf("%w: %s", errLocked, l.path)
+}
+
+func (l *fileLock) refresh(context.Context) error {
+	bs, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	if !ours(string(bs)) {
+		return fmt.Errorf("lock %s was taken over by %s", l.path, strings.TrimSpace(string(bs)))
+	}
+	tmp := fmt.Sprintf("%s.new.%d", l.path, os.Getpid())
+	if err := ioutil.WriteFile(tmp, []byte(lockHolder()+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *fileLock) release() error {
+	bs, err := ioutil.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	if !ours(string(bs)) {
+		return fmt.Errorf("lock %s was taken over by %s", l.path, strings.TrimSpace(string(bs)))
+	}
+	return os.Remove(l.path)
+}
+
+// githubLock is a ref pointing to a commit whose message is the holder.
+// Ref creation fails if the ref exists, which makes it a lock. Taking
+// over and refreshing the lock adds a commit on top of the one we read,
+// without forcing, so that it fails if someone else moved the ref in the
+// meantime.
+type githubLock struct {
+	client      *github.Client
+	owner, repo string
+	sha         string // of our lock commit
+}
+
+func (l *githubLock) acquire(ctx context.Context, ttl time.Duration) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		sha, err := l.commit(ctx, "")
+		if err != nil {
+			return err
+		}
+		_, resp, err := l.client.Git.CreateRef(ctx, l.owner, l.repo, &github.Reference{
+			Ref:    github.String(lockRef),
+			Object: &github.GitObject{SHA: github.String(sha)},
+		})
+		if err == nil {
+			l.sha = sha
+			return nil
+		}
+		if resp == nil || resp.StatusCode != http.StatusUnprocessableEntity {
+			return fmt.Errorf("creating lock ref: %w", err)
+		}
+
+		// The ref exists; see who holds it and since when.
+		ref, _, err := l.client.Git.GetRef(ctx, l.owner, l.repo, lockRef)
+		if err != nil {
+			return fmt.Errorf("reading lock ref: %w", err)
+		}
+		held, err := l.holder(ctx, ref.GetObject())
+		if err != nil {
+			return err
+		}
+		if t := lockTime(held); t.IsZero() || time.Since(t) < ttl {
+			return fmt.Errorf("%w: %s (%s in %s/%s)", errLocked, held, lockRef, l.owner, l.repo)
+		}
+		log.Printf("Taking over abandoned lock in %s/%s held by %s", l.owner, l.repo, held)
+		if ref.GetObject().GetType() == "blob" {
+			// A lock of an earlier version, which can't be built on;
+			// remove it and start over.
+			if _, err := l.client.Git.DeleteRef(ctx, l.owner, l.repo, lockRef); err != nil {
+				return fmt.Errorf("removing abandoned lock: %w", err)
+			}
+			continue
+		}
+		if err := l.update(ctx, ref.GetObject().GetSHA()); err != nil {
+			return fmt.Errorf("%w: taking over %s in %s/%s: %v", errLocked, lockRef, l.owner, l.repo, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s in %s/%s", errLocked, lockRef, l.owner, l.repo)
+}
+
+func (l *githubLock) refresh(ctx context.Context) error {
+	if err := l.update(ctx, l.sha); err != nil {
+		return fmt.Errorf("lock %s in %s/%s was taken over: %w", lockRef, l.owner, l.repo, err)
+	}
+	return nil
+}
+
+func (l *githubLock) release() error {
+	ctx := context.Background()
+	ref, _, err := l.client.Git.GetRef(ctx, l.owner, l.repo, lockRef)
+	if err != nil {
+		return err
+	}
+	if ref.GetObject().GetSHA() != l.sha {
+		return fmt.Errorf("lock %s in %s/%s was taken over", lockRef, l.owner, l.repo)
+	}
+	_, err = l.client.Git.DeleteRef(ctx, l.owner, l.repo, lockRef)
+	return err
+}
+
+// update moves the lock ref from parent to a new lock commit on top of it.
+func (l *githubLock) update(ctx context.Context, parent string) error {
+	sha, err := l.commit(ctx, parent)
+	if err != nil {
+		return err
+	}
+	_, _, err = l.client.Git.UpdateRef(ctx, l.owner, l.repo, &github.Reference{
+		Ref:    github.String(lockRef),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}, false)
+	if err != nil {
+		return err
+	}
+	l.sha = sha
+	return nil
+}
+
+// commit creates a lock commit with the given parent, if any.
+func (l *githubLock) commit(ctx context.Context, parent string) (string, error) {
+	holder := lockHolder()
+	blob, _, err := l.client.Git.CreateBlob(ctx, l.owner, l.repo, &github.Blob{
+		Content:  github.String(holder),
+		Encoding: github.String("utf-8"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating lock blob: %w", err)
+	}
+	tree, _, err := l.client.Git.CreateTree(ctx, l.owner, l.repo, "", []github.TreeEntry{
+		{Path: github.String("holder"), Mode: github.String("100644"), Type: github.String("blob"), SHA: blob.SHA},
+	})
+	if err != nil {
+		return "", fmt.Errorf("creating lock tree: %w", err)
+	}
+	c := &github.Commit{Message: github.String(holder), Tree: tree}
+	if parent != "" {
+		c.Parents = []github.Commit{{SHA: github.String(parent)}}
+	}
+	commit, _, err := l.client.Git.CreateCommit(ctx, l.owner, l.repo, c)
+	if err != nil {
+		return "", fmt.Errorf("creating lock commit: %w", err)
+	}
+	return commit.GetSHA(), nil
+}
+
+// holder returns the holder string of the lock ref's object: a commit, or
+// a blob for locks of earlier versions.
+func (l *githubLock) holder(ctx context.Context, obj *github.GitObject) (string, error) {
+	if obj.GetType() == "commit" {
+		c, _, err := l.client.Git.GetCommit(ctx, l.owner, l.repo, obj.GetSHA())
+		if err != nil {
+			return "", fmt.Errorf("reading lock commit: %w", err)
+		}
+		return strings.TrimSpace(c.GetMessage()), nil
+	}
+	held, _, err := l.client.Git.GetBlob(ctx, l.owner, l.repo, obj.GetSHA())
+	if err != nil {
+		return "", fmt.Errorf("reading lock blob: %w", err)
+	}
+	content := held.GetContent()
+	if held.GetEncoding() == "base64" {
+		bs, _ := base64.StdEncoding.DecodeString(content)
+		content = string(bs)
+	}
+	return strings.TrimSpace(content), nil
+}
@@ -1,294 +1,649 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
+	"calmh.dev/freezebot/freeze"
 	"github.com/google/go-github/github"
+	"github.com/gregjones/httpcache"
+	"github.com/gregjones/httpcache/diskcache"
+	"github.com/peterbourgon/diskv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/oauth2"
 )
 
-const retries = 5
+// Exit codes, one per error category.
+const (
+	exitAPIError      = 1
+	exitConfigError   = 2
+	exitSkippedRepos  = 3
+	exitFailedActions = 4
+	exitRateLimited   = 5
+	exitAuthError     = 6
+)
 
-type configEntry struct {
-	Owner      string
-	Repos      []string
-	Directives []configDirective
-}
+// concurrentPace is the default least time between API requests when
+// processing repos in parallel, to stay clear of the secondary rate limits.
+const concurrentPace = 250 * time.Millisecond
 
-type configDirective struct {
-	Query          string
-	State          string
-	DaysClosed     int
-	DaysNotUpdated int
-	Label          string
-	Lock           bool
-	Close          bool
-	CloseComment   string
+// failConditions are the exit codes that -fail-on can turn off, by name.
+var failConditions = map[string]int{
+	"skipped-repos":  exitSkippedRepos,
+	"failed-actions": exitFailedActions,
+	"rate-limit":     exitRateLimited,
 }
 
+// failOn holds the exit codes of the conditions given by -fail-on.
+var failOn = map[int]bool{exitSkippedRepos: true, exitFailedActions: true, exitRateLimited: true}
+
+const exitCodeUsage = `
+Exit codes:
+  0	success
+  1	GitHub API or other error, run aborted
+  2	configuration error
+  3	completed, but some repos were skipped after errors
+  4	completed, but some actions failed
+  5	rate limited, run aborted
+  6	authentication error (bad or missing token)
+
+Codes 3 to 5 are only used for the conditions given by -fail-on; otherwise
+the run exits with 0 after logging the error.
+`
+
+const commandUsage = `Usage:
+  %[1]s [flags]               apply the configuration
+  %[1]s plan [flags]          write the planned actions to the -out file
+  %[1]s apply [flags] FILE    perform the actions of a plan file
+  %[1]s estimate [flags]      summarize the planned actions per directive
+  %[1]s simulate -as-of DATE  estimate what the directives would have done at a
+                              past date
+  %[1]s webhook [flags]       evaluate the directives against issues as their
+                              webhooks arrive, on -webhook-listen
+  %[1]s render-policy         write the directives as a Markdown policy
+                              document, for CONTRIBUTING.md or similar
+  %[1]s explain [flags]       describe how each directive will be carried out
+  %[1]s observe [flags]       record who closes, locks and labels the issues the
+                              directives select, without acting, as JSON lines
+  %[1]s bootstrap-state       seed the state with the issues we closed and
+                              locked, and the comments already seen
+  %[1]s decrypt-audit FILE    print an encrypted audit log in clear text
+  %[1]s housekeeping [flags]  update the draft release or designated issue with
+                              the issues closed since the latest release
+  %[1]s sync-labels [flags]   create and update the labels of each entry's
+                              label set, and those the directives use
+
+Flags:
+`
+
 func main() {
+	cmd, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
 	token := flag.String("token", os.Getenv("GITHUB_TOKEN"), "GitHub token")
+	baseURL := flag.String("base-url", "", "GitHub Enterprise Server API base URL, e.g. https://ghe.example.com/api/v3/ (overrides the config)")
+	uploadURL := flag.String("upload-url", "", "GitHub Enterprise Server upload URL (default derived from -base-url)")
+	appID := flag.Int64("app-id", 0, "Authenticate as this GitHub App instead of using -token")
+	appInstallation := flag.Int64("app-installation-id", 0, "GitHub App installation ID")
+	appKeyFile := flag.String("app-key-file", "", "GitHub App private key file (PEM)")
 	cfgFile := flag.String("config", "config.json", "Configuration file")
-	flag.Parse()
+	cfgFormat := flag.String("config-format", "", "Configuration file format, \"json\", \"yaml\" or \"toml\" (default from the file extension)")
+	stateFile := flag.String("state", "", "Where to keep state across runs: a JSON file, sqlite:FILE, or a redis:// URL (overrides the config)")
+	stateKeyFile := flag.String("state-key-file", "", "File holding the key to encrypt the state file and audit log with (default $FREEZEBOT_STATE_KEY; unencrypted if neither)")
+	repoBudget := flag.Duration("repo-time-budget", 0, "Maximum time to spend on a single repo per run (0 for unlimited)")
+	concurrency := flag.Int("concurrency", 1, "Number of repos to process in parallel; API requests are then spaced at least -pace-min apart (default 250ms) over all of them")
+	pageConcurrency := flag.Int("page-concurrency", 4, "Number of issue pages to fetch ahead concurrently within a repo; applies to query directives, and to listings only with -rest")
+	useREST := flag.Bool("rest", false, "List issues with the REST API instead of GraphQL")
+	auditLog := flag.String("audit-log", "", "Append performed actions to this file, as JSON lines; may be an s3:// or gs:// URL")
+	metricsListen := flag.String("metrics-listen", "", "Address to serve Prometheus metrics on, e.g. \":2112\"")
+	out := flag.String("out", "plan.json", "Plan file to write, for the plan command; may be an s3:// or gs:// URL")
+	planOut := flag.String("plan-out", "", "Same as the plan command with -out")
+	approve := flag.String("approve", "", "Same as the apply command")
+	planKeyFile := flag.String("plan-key-file", "", "File holding the plan signing key (default $FREEZEBOT_PLAN_KEY)")
+	distinctApprover := flag.Bool("distinct-approver", false, "Require plans to be approved by a different user than the one who created them")
+	progressInterval := flag.Duration("progress-interval", time.Minute, "How often to log progress during a run (0 to disable)")
+	membershipTTL := flag.Duration("membership-ttl", 0, "Keep org member and collaborator lists in the state file for this long (0 to fetch every run)")
+	httpCache := flag.String("http-cache", "", "Directory for an on-disk cache of GitHub API responses, revalidated with conditional requests")
+	httpCacheSize := flag.Int64("http-cache-size", 100, "Maximum size of the HTTP cache, in MiB; enforced at startup")
+	lockSpec := flag.String("lock", "", "Lock to hold while running, to prevent overlapping runs: file:PATH or github:OWNER/REPO")
+	lockTTL := flag.Duration("lock-ttl", 6*time.Hour, "Take over locks held for longer than this, as abandoned")
+	rateLimitWait := flag.Duration("rate-limit-wait", time.Hour, "Wait up to this long for the API rate limit to reset when exhausted (0 to fail instead)")
+	asOf := flag.String("as-of", "", "Date (RFC 3339 or YYYY-MM-DD) to simulate, for the simulate command")
+	observeSince := flag.Duration("observe-since", 30*24*time.Hour, "How far back to look for events, for the observe command (0 for all)")
+	webhookListen := flag.String("webhook-listen", ":8080", "Address to receive webhooks on, for the webhook command")
+	webhookSecretFile := flag.String("webhook-secret-file", "", "File holding the webhook secret (default $FREEZEBOT_WEBHOOK_SECRET)")
+	daemonMode := flag.Bool("daemon", false, "Stay running, running each config entry on its schedule")
+	paceMin := flag.Duration("pace-min", 0, "Least time between API requests, with adaptive pacing")
+	paceMax := flag.Duration("pace-max", 0, "Most time between API requests when GitHub is slow or failing (0 to disable adaptive pacing)")
+	paceSlow := flag.Duration("pace-slow", 5*time.Second, "Latency above which an API request counts as slow, for adaptive pacing")
+	botLogin := flag.String("bot-login", "", "Our login, e.g. \"freezebot[bot]\" for a GitHub App, for the bootstrap-state command (default the token's user)")
+	verbose := flag.Bool("v", false, "Also log why each issue was skipped")
+	quiet := flag.Bool("q", false, "Log only warnings and errors")
+	debugAPI := flag.Bool("debug-api", false, "Log the endpoint, duration, status and rate limit cost of each API request")
+	profileName := flag.String("profile", "", "Limit what any config may do: \"conservative\" (label, comment and lock only, thresholds of at least 180 days), \"standard\" (no transfers or discussions, at least 30 days) or \"aggressive\" (no limits)")
+	failOnList := flag.String("fail-on", "skipped-repos,failed-actions,rate-limit", "Conditions to exit with an error for, comma separated (see below; empty for none)")
+	dryRun := flag.Bool("dry-run", false, "Log the actions that would be performed, without performing them")
+	requireYes := flag.Bool("require-yes", false, "Make -dry-run the default, so that actions are only performed with -yes (also settable in the config)")
+	yes := flag.Bool("yes", false, "Perform actions, when -require-yes or the config makes -dry-run the default")
+	now := flag.String("now", "", "Evaluate thresholds as of this time (RFC 3339 or YYYY-MM-DD) instead of the current time")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), commandUsage, os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprint(flag.CommandLine.Output(), exitCodeUsage)
+	}
+	flag.CommandLine.Parse(args)
 
 	log.SetOutput(os.Stdout)
-
-	bs, err := ioutil.ReadFile(*cfgFile)
-	if err != nil {
-		log.Println("Reading config:", err)
-		os.Exit(1)
+	switch {
+	case *verbose && *quiet:
+		fatal("Parsing flags", &freeze.ConfigError{Err: errors.New("-v and -q are mutually exclusive")})
+	case *verbose:
+		freeze.SetLogLevel(freeze.LogDebug)
+	case *quiet:
+		freeze.SetLogLevel(freeze.LogWarn)
 	}
 
-	var cfgs []configEntry
-	if err := json.Unmarshal(bs, &cfgs); err != nil {
-		log.Println("Reading config:", err)
-		os.Exit(1)
+	if err := parseFailOn(*failOnList); err != nil {
+		fatal("Parsing -fail-on", err)
 	}
 
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: *token},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-	client := github.NewClient(tc)
+	planFile := flag.Arg(0)
+	switch {
+	case *planOut != "":
+		cmd, *out = "plan", *planOut
+	case *approve != "":
+		cmd, planFile = "apply", *approve
+	}
 
-	for _, cfg := range cfgs {
-		if cfg.Owner == "" {
-			log.Println("Every config entry must set `owner`")
-			os.Exit(2)
+	if cmd == "decrypt-audit" {
+		if err := decryptAudit(os.Stdout, flag.Arg(0), *stateKeyFile); err != nil {
+			fatal("Decrypting audit log", err)
 		}
+		return
+	}
 
-		for _, repo := range cfg.Repos {
-			log.Printf("Processing %s/%s", cfg.Owner, repo)
-			handleRepoIssues(ctx, client, cfg.Owner, repo, cfg.Directives)
+	profile, err := freeze.LookupProfile(*profileName)
+	if err != nil {
+		fatal("Profile", err)
+	}
+
+	var cfg freeze.Config
+	switch cmd {
+	case "run", "plan", "apply", "estimate", "explain", "render-policy", "housekeeping", "sync-labels", "bootstrap-state", "observe", "simulate", "webhook":
+		if cmd == "apply" && planFile == "" {
+			fatal("Apply", &freeze.ConfigError{Err: errors.New("no plan file given")})
 		}
-		if len(cfg.Repos) > 0 {
-			// We're done
-			continue
+		var err error
+		cfg, err = loadConfig(*cfgFile, *cfgFormat)
+		if err != nil {
+			fatal("Reading config", err)
 		}
-
-		listOpts := &github.RepositoryListOptions{
-			ListOptions: github.ListOptions{
-				PerPage: 100,
-			},
+		if err := profile.Apply(&cfg); err != nil {
+			fatal("Profile", err)
 		}
+	default:
+		fatal("Command", &freeze.ConfigError{Err: fmt.Errorf("unknown command %q", cmd)})
+	}
+	if (*requireYes || cfg.RequireYes) && !*yes && !*dryRun {
+		log.Println("Dry run, as -yes is required to perform actions")
+		*dryRun = true
+	}
 
-		for {
-			rs, resp, err := client.Repositories.List(ctx, cfg.Owner, listOpts)
-			if err != nil {
-				log.Println(err)
-				os.Exit(1)
-			}
+	switch cmd {
+	case "explain":
+		freeze.Explain(os.Stdout, cfg)
+		return
+	case "render-policy":
+		freeze.RenderPolicy(os.Stdout, cfg)
+		return
+	}
 
-			for _, repo := range rs {
-				log.Println("Processing", repo.GetFullName())
-				handleRepoIssues(ctx, client, cfg.Owner, repo.GetName(), cfg.Directives)
-			}
+	clk, err := parseClock(*now)
+	if err != nil {
+		fatal("Parsing -now", &freeze.ConfigError{Err: err})
+	}
 
-			if resp.NextPage == 0 {
-				break
+	stateKey, err := readKey(*stateKeyFile, "FREEZEBOT_STATE_KEY")
+	if err != nil {
+		fatal("Reading state key", err)
+	}
+	if *stateFile == "" {
+		*stateFile = cfg.State
+	}
+	st, err := freeze.LoadEncryptedState(*stateFile, stateKey)
+	if err != nil {
+		fatal("Reading state", err)
+	}
+	if *dryRun || cmd == "plan" || cmd == "estimate" {
+		// Don't let a dry run or a plan move checkpoints and the like.
+		st = st.ReadOnly()
+	}
+
+	if *metricsListen != "" {
+		http.Handle("/metrics", promhttp.Handler())
+		go func() {
+			if err := http.ListenAndServe(*metricsListen, nil); err != nil {
+				log.Println("Serving metrics:", err)
 			}
-			listOpts.Page = resp.NextPage
-		}
+		}()
 	}
-}
 
-func handleRepoIssues(ctx context.Context, client *github.Client, owner, repo string, directives []configDirective) {
-	for _, directive := range directives {
-		issues, err := findIssues(ctx, client, owner, repo, directive)
+	if *baseURL == "" {
+		*baseURL = cfg.BaseURL
+	}
+	if *uploadURL == "" {
+		*uploadURL = cfg.UploadURL
+	}
+	if *baseURL != "" && !strings.HasSuffix(*baseURL, "/") {
+		*baseURL += "/"
+	}
+	if *baseURL != "" && *uploadURL == "" {
+		*uploadURL = strings.Replace(*baseURL, "/api/v3/", "/api/uploads/", 1)
+	}
+	apiURL := *baseURL
+	if apiURL == "" {
+		apiURL = "https://api.github.com/"
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	ts := oauth2.StaticTokenSource(
+		&oauth2.Token{AccessToken: *token},
+	)
+	if *appID != 0 {
+		ts, err = newAppTokenSource(*appID, *appInstallation, *appKeyFile, apiURL)
 		if err != nil {
-			log.Println("Finding issues:", err)
-			os.Exit(1)
+			fatal("GitHub App authentication", &freeze.AuthError{Err: err})
 		}
-
-		for _, i := range issues {
-			handleIssue(ctx, client, owner, repo, i, directive)
+		appIdentity = fmt.Sprintf("app/%d", *appID)
+	}
+	tc := oauth2.NewClient(ctx, ts)
+	requests := &countingTransport{next: tc.Transport}
+	tc.Transport = requests
+	if *debugAPI {
+		tc.Transport = &timingTransport{next: tc.Transport}
+	}
+	if *concurrency > 1 && *paceMin == 0 {
+		*paceMin = concurrentPace
+	}
+	if *paceMax > 0 || *concurrency > 1 {
+		max := *paceMax
+		if max < *paceMin {
+			max = *paceMin
 		}
+		tc.Transport = &freeze.PacingTransport{Transport: tc.Transport, MinDelay: *paceMin, MaxDelay: max, SlowLatency: *paceSlow}
 	}
-}
-
-func findIssues(ctx context.Context, client *github.Client, owner, repo string, directive configDirective) ([]github.Issue, error) {
-	if directive.Query != "" {
-		return findIssuesByQuery(ctx, client, owner, repo, directive)
+	if *rateLimitWait > 0 {
+		tc.Transport = &freeze.RateLimitTransport{Transport: tc.Transport, MaxWait: *rateLimitWait}
+	}
+	if *httpCache != "" {
+		// Responses are revalidated with their ETag or Last-Modified
+		// before use; conditional requests answered with 304 Not
+		// Modified don't count against the rate limit.
+		if err := pruneCache(*httpCache, *httpCacheSize<<20); err != nil {
+			log.Println("Pruning HTTP cache:", err)
+		}
+		dv := diskv.New(diskv.Options{
+			BasePath:     *httpCache,
+			CacheSizeMax: 16 << 20, // in memory
+		})
+		stats := &cacheStatsTransport{next: &revalidateTransport{next: &httpcache.Transport{
+			Transport:           tc.Transport,
+			Cache:               diskcache.NewWithDiskv(dv),
+			MarkCachedResponses: true,
+		}}}
+		tc.Transport = stats
+		onExit(stats.logStats)
+	}
+	client := github.NewClient(tc)
+	if *baseURL != "" {
+		client, err = github.NewEnterpriseClient(*baseURL, *uploadURL, tc)
+		if err != nil {
+			fatal("Setting up client", &freeze.ConfigError{Err: err})
+		}
 	}
-	return findIssuesByList(ctx, client, owner, repo, directive)
-}
 
-func findIssuesByList(ctx context.Context, client *github.Client, owner, repo string, directive configDirective) ([]github.Issue, error) {
-	opts := &github.IssueListByRepoOptions{
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
+	if *lockSpec != "" && cmd != "estimate" {
+		unlock, err := acquireLock(ctx, client, *lockSpec, *lockTTL)
+		if err != nil {
+			fatal("Locking", err)
+		}
+		onExit(func() {
+			if err := unlock(); err != nil {
+				log.Println("Unlocking:", err)
+			}
+		})
 	}
 
-	if directive.State != "" {
-		opts.State = directive.State
+	r := &freeze.Runner{
+		Client:           client,
+		Clock:            clk,
+		State:            st,
+		RepoTimeBudget:   *repoBudget,
+		PageConcurrency:  *pageConcurrency,
+		REST:             *useREST,
+		Concurrency:      *concurrency,
+		MembershipTTL:    *membershipTTL,
+		ProgressInterval: *progressInterval,
 	}
 
-	var res []github.Issue
+	if *auditLog != "" {
+		fd, err := openOutput(*auditLog, "audit.jsonl")
+		if err != nil {
+			fatal("Opening audit log", err)
+		}
+		onExit(func() {
+			if err := fd.Close(); err != nil {
+				log.Println("Closing audit log:", err)
+			}
+		})
+		var w io.Writer = fd
+		if stateKey != nil {
+			w = &freeze.EncryptedLineWriter{W: fd, Secret: stateKey}
+		}
+		r.Sink = &freeze.GitHubSink{Client: client, Audit: freeze.NewJSONSink(w), State: st, Clock: clk}
+	}
+	if *dryRun {
+		r.Sink = &freeze.PrintSink{W: os.Stdout, Prefix: "DRY-RUN: "}
+	}
 
-	for {
-		is, resp, err := client.Issues.ListByRepo(ctx, owner, repo, opts)
+	switch cmd {
+	case "plan", "apply":
+		key, err := planKey(*planKeyFile)
 		if err != nil {
-			return nil, err
+			fatal("Reading plan key", err)
+		}
+		if cmd == "apply" {
+			err = applyPlan(ctx, r, cfg, planFile, key, *distinctApprover, profile)
+		} else {
+			err = writePlan(ctx, r, cfg, *out, key)
+		}
+		if err != nil {
+			fatal("Plan", err)
 		}
 
-		for _, i := range is {
-			res = append(res, *i)
+	case "webhook":
+		secret, err := readKey(*webhookSecretFile, "FREEZEBOT_WEBHOOK_SECRET")
+		if err != nil {
+			fatal("Reading webhook secret", err)
+		}
+		if secret == nil {
+			fatal("Webhook", &freeze.ConfigError{Err: errors.New("no webhook secret given")})
+		}
+		if err := serveWebhooks(ctx, r, cfg, *webhookListen, secret); err != nil {
+			fatal("Webhook", err)
 		}
 
-		if resp.NextPage == 0 {
-			break
+	case "observe":
+		var since time.Time
+		if *observeSince > 0 {
+			since = clk.Now().Add(-*observeSince)
+		}
+		if err := observe(ctx, r, cfg, since, os.Stdout); err != nil {
+			fatal("Observing", err)
 		}
-		opts.Page = resp.NextPage
-	}
 
-	return res, nil
-}
+	case "housekeeping":
+		if err := r.Housekeeping(ctx, cfg, *dryRun); err != nil {
+			fatal("Housekeeping", err)
+		}
 
-func findIssuesByQuery(ctx context.Context, client *github.Client, owner, repo string, directive configDirective) ([]github.Issue, error) {
-	opts := &github.SearchOptions{
-		Sort:  "created",
-		Order: "asc",
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
-	}
+	case "sync-labels":
+		if err := r.SyncLabels(ctx, cfg, *dryRun); err != nil {
+			fatal("Syncing labels", err)
+		}
 
-	query := fmt.Sprintf("%s repo:%s/%s", directive.Query, owner, repo)
-	var res []github.Issue
+	case "bootstrap-state":
+		me := *botLogin
+		if me == "" {
+			if appIdentity != "" {
+				fatal("Bootstrap", &freeze.ConfigError{Err: errors.New("-bot-login is required with a GitHub App")})
+			}
+			if me, err = currentUser(ctx, client); err != nil {
+				fatal("Bootstrap", err)
+			}
+		}
+		if err := r.Bootstrap(ctx, cfg, me); err != nil {
+			fatal("Bootstrap", err)
+		}
 
-	for {
-		is, resp, err := client.Search.Issues(ctx, query, opts)
+	case "simulate":
+		if *asOf == "" {
+			fatal("Simulate", &freeze.ConfigError{Err: errors.New("-as-of is required")})
+		}
+		t, err := parseClock(*asOf)
 		if err != nil {
-			return nil, err
+			fatal("Parsing -as-of", &freeze.ConfigError{Err: err})
+		}
+		r.Clock, r.AsOf = t, t.Now()
+		r.State = r.State.ReadOnly()
+		log.Printf("Simulating as of %s; search queries see the current state of issues", r.AsOf.Format(time.RFC3339))
+		est, err := r.Estimate(ctx, cfg)
+		printEstimate(os.Stdout, est)
+		if err != nil {
+			fatal("Simulating", err)
 		}
 
-		res = append(res, is.Issues...)
+	case "estimate":
+		est, err := r.Estimate(ctx, cfg)
+		printEstimate(os.Stdout, est)
+		if err != nil {
+			fatal("Estimating", err)
+		}
 
-		if resp.NextPage == 0 {
+	default:
+		if err := r.Changelog(ctx, cfg); err != nil {
+			log.Println("Changelog:", err)
+		}
+		if *daemonMode {
+			if err := daemon(ctx, r, cfg); err != nil {
+				fatal("Daemon", err)
+			}
 			break
 		}
-		opts.Page = resp.NextPage
+		err := r.Run(ctx, cfg)
+		r.WriteSummary(os.Stdout, requests.count())
+		if err != nil {
+			fatal("Running", err)
+		}
 	}
-
-	return res, nil
+	runExitHooks()
 }
 
-func handleIssue(ctx context.Context, client *github.Client, owner, repo string, i github.Issue, directive configDirective) {
-	if i.GetLocked() {
-		// Never touch locked issues
-		return
-	}
-	if directive.DaysClosed > 0 && daysSince(i.GetClosedAt()) < directive.DaysClosed {
-		// Check days closed if set
-		return
+// observe writes the observations as JSON lines, followed by a count of
+// events per actor and kind on stderr.
+func observe(ctx context.Context, r *freeze.Runner, cfg freeze.Config, since time.Time, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	counts := make(map[string]int)
+	err := r.Observe(ctx, cfg, since, func(o freeze.Observation) error {
+		counts[o.Actor+"\t"+o.Kind]++
+		return enc.Encode(o)
+	})
+
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
 	}
-	if directive.DaysNotUpdated > 0 && daysSince(i.GetUpdatedAt()) < directive.DaysNotUpdated {
-		// Check days not updated if set
-		return
+	sort.Strings(keys)
+	tw := tabwriter.NewWriter(os.Stderr, 2, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACTOR\tEVENT\tCOUNT")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%s\t%d\n", k, counts[k])
 	}
+	tw.Flush()
+	return err
+}
 
-	if directive.Label != "" && !contains(i.Labels, directive.Label) {
-		log.Printf("Labeling issue %d %q", i.GetNumber(), directive.Label)
-		labelIssue(ctx, client, owner, repo, i.GetNumber(), directive.Label)
+func printEstimate(w io.Writer, est freeze.Estimate) {
+	tw := tabwriter.NewWriter(w, 2, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "DIRECTIVE\tISSUES\tACTIONS")
+	for _, b := range freeze.NewAgeBuckets() {
+		fmt.Fprintf(tw, "\t%sd", b)
 	}
-
-	if directive.Close && i.GetState() != "closed" {
-		if directive.CloseComment != "" {
-			log.Printf("Commenting on issue %d", i.GetNumber())
-			commentIssue(ctx, client, owner, repo, i.GetNumber(), directive.CloseComment)
+	fmt.Fprintln(tw)
+	for _, de := range est.Directives {
+		kinds := make([]string, 0, len(de.Actions))
+		for kind, n := range de.Actions {
+			kinds = append(kinds, fmt.Sprintf("%s=%d", kind, n))
 		}
-		log.Printf("Closing issue %d", i.GetNumber())
-		closeIssue(ctx, client, owner, repo, i.GetNumber())
-	}
-
-	if directive.Lock {
-		log.Printf("Locking issue %d", i.GetNumber())
-		lockIssue(ctx, client, owner, repo, i.GetNumber())
+		sort.Strings(kinds)
+		fmt.Fprintf(tw, "%s\t%d\t%s", de.Directive, de.Issues, strings.Join(kinds, ","))
+		for _, b := range de.Ages {
+			fmt.Fprintf(tw, "\t%d", b.Issues)
+		}
+		fmt.Fprintln(tw)
 	}
+	tw.Flush()
 }
 
-func labelIssue(ctx context.Context, client *github.Client, owner, repo string, number int, label string) {
-	var err error
-	for i := 0; i < retries; i++ {
-		_, _, err = client.Issues.AddLabelsToIssue(ctx, owner, repo, number, []string{label})
-		if err == nil {
-			return
-		}
-		log.Printf("Adding label to issue %d: %v (retrying)\n", number, err)
-		time.Sleep(time.Duration(i) * time.Second)
+func decryptAudit(w io.Writer, path, keyFile string) error {
+	key, err := readKey(keyFile, "FREEZEBOT_STATE_KEY")
+	if err != nil {
+		return err
 	}
+	if key == nil {
+		return &freeze.ConfigError{Err: errors.New("no key given")}
+	}
+	fd, err := os.Open(path)
 	if err != nil {
-		log.Printf("Adding label to issue %d: %v\n", number, err)
-		os.Exit(1)
+		return err
 	}
-}
+	defer fd.Close()
 
-func lockIssue(ctx context.Context, client *github.Client, owner, repo string, number int) {
-	var err error
-	for i := 0; i < retries; i++ {
-		_, err := client.Issues.Lock(ctx, owner, repo, number, nil)
-		if err == nil {
-			return
+	sc := bufio.NewScanner(fd)
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		bs, err := freeze.DecryptLine(key, sc.Text())
+		if err != nil {
+			return err
 		}
-		log.Printf("Locking issue %d: %v (retrying)\n", number, err)
-		time.Sleep(time.Duration(i) * time.Second)
-	}
-	if err != nil {
-		log.Printf("Locking issue %d: %v\n", number, err)
-		os.Exit(1)
+		w.Write(bs)
 	}
+	return sc.Err()
 }
 
-func closeIssue(ctx context.Context, client *github.Client, owner, repo string, number int) {
-	var err error
-	for i := 0; i < retries; i++ {
-		_, _, err := client.Issues.Edit(ctx, owner, repo, number, &github.IssueRequest{State: github.String("closed")})
-		if err == nil {
-			return
+// loadConfig reads the config file in the given format, or the one implied
+// by the file extension.
+func loadConfig(path, format string) (freeze.Config, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return freeze.Config{}, &freeze.ConfigError{Err: err}
+	}
+	if format == "" {
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".yaml", ".yml", ".toml":
+			format = ext[1:]
+		default:
+			format = "json"
 		}
-		log.Printf("Closing issue %d: %v (retrying)\n", number, err)
-		time.Sleep(time.Duration(i) * time.Second)
 	}
-	if err != nil {
-		log.Printf("Closing issue %d: %v\n", number, err)
-		os.Exit(1)
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		return freeze.ParseYAMLConfig(bs)
+	case "toml":
+		return freeze.ParseTOMLConfig(bs)
+	case "json":
+		return freeze.ParseConfig(bs)
+	default:
+		return freeze.Config{}, &freeze.ConfigError{Err: fmt.Errorf("unknown config format %q", format)}
 	}
 }
 
-func commentIssue(ctx context.Context, client *github.Client, owner, repo string, number int, comment string) {
-	var err error
-	for i := 0; i < retries; i++ {
-		_, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(comment)})
-		if err == nil {
-			return
+var exitHooks []func()
+
+// onExit registers fn to be called before exiting, including on fatal
+// errors.
+func onExit(fn func()) {
+	exitHooks = append(exitHooks, fn)
+}
+
+func runExitHooks() {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	exitHooks = nil
+}
+
+func fatal(what string, err error) {
+	log.Printf("%s: %v", what, err)
+	runExitHooks()
+	code := exitCode(err)
+	for _, c := range failConditions {
+		if c == code && !failOn[code] {
+			code = 0
 		}
-		log.Printf("Commenting on issue %d: %v (retrying)\n", number, err)
-		time.Sleep(time.Duration(i) * time.Second)
 	}
-	if err != nil {
-		log.Printf("Commenting on issue %d: %v\n", number, err)
-		os.Exit(1)
+	os.Exit(code)
+}
+
+// parseFailOn sets failOn from the comma separated condition names.
+func parseFailOn(s string) error {
+	failOn = make(map[int]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		code, ok := failConditions[name]
+		if !ok {
+			return &freeze.ConfigError{Err: fmt.Errorf("unknown condition %q", name)}
+		}
+		failOn[code] = true
 	}
+	return nil
 }
 
-func daysSince(t time.Time) int {
-	return int(time.Since(t) / 24 / time.Hour)
+func exitCode(err error) int {
+	var ce *freeze.ConfigError
+	var ae *freeze.AuthError
+	var rle *freeze.RateLimitError
+	var pfe *freeze.PartialFailureError
+	switch {
+	case errors.As(err, &ce):
+		return exitConfigError
+	case errors.As(err, &ae):
+		return exitAuthError
+	case errors.As(err, &rle):
+		return exitRateLimited
+	case errors.As(err, &pfe) && pfe.Failed > 0:
+		return exitSkippedRepos
+	case errors.As(err, &pfe):
+		return exitFailedActions
+	default:
+		return exitAPIError
+	}
 }
 
-func contains(l []github.Label, t string) bool {
-	for _, s := range l {
-		if s.GetName() == t {
-			return true
+// parseClock returns a real clock for the empty string, or a clock fixed at
+// the given RFC 3339 timestamp or date.
+func parseClock(s string) (freeze.Clock, error) {
+	if s == "" {
+		return freeze.RealClock{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, err
 		}
 	}
-	return false
+	return freeze.FixedClock(t), nil
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// openOutput opens a local file for appending, or, for "s3://bucket/key"
+// and "gs://bucket/key" targets, a buffer that is uploaded to the bucket
+// on Close. Keys ending in a slash get a timestamped file name, as objects
+// can't be appended to.
+//
+// S3 credentials are taken from AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY, and the endpoint from AWS_ENDPOINT_URL if set, for
+// S3 compatible services. GCS is accessed through its S3 compatible API
+// with HMAC keys from GCS_HMAC_ACCESS_ID and GCS_HMAC_SECRET.
+func openOutput(target, name string) (io.WriteCloser, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "s3" && u.Scheme != "gs") {
+		return os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	}
+
+	var endpoint string
+	var creds *credentials.Credentials
+	secure := true
+	switch u.Scheme {
+	case "s3":
+		endpoint = "s3.amazonaws.com"
+		if ep := os.Getenv("AWS_ENDPOINT_URL"); ep != "" {
+			epu, err := url.Parse(ep)
+			if err != nil {
+				return nil, fmt.Errorf("AWS_ENDPOINT_URL: %w", err)
+			}
+			endpoint, secure = epu.Host, epu.Scheme != "http"
+		}
+		creds = credentials.NewEnvAWS()
+	case "gs":
+		endpoint = "storage.googleapis.com"
+		creds = credentials.NewStaticV4(os.Getenv("GCS_HMAC_ACCESS_ID"), os.Getenv("GCS_HMAC_SECRET"), "")
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{Creds: creds, Secure: secure})
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" || strings.HasSuffix(key, "/") {
+		key += time.Now().UTC().Format("20060102T150405Z") + "-" + name
+	}
+	return &bucketWriter{client: client, bucket: u.Host, key: key}, nil
+}
+
+type bucketWriter struct {
+	client *minio.Client
+	bucket string
+	key    string
+	buf    bytes.Buffer
+}
+
+func (w *bucketWriter) Write(bs []byte) (int, error) {
+	return w.buf.Write(bs)
+}
+
+func (w *bucketWriter) Close() error {
+	_, err := w.client.PutObject(context.Background(), w.bucket, w.key, &w.buf, int64(w.buf.Len()), minio.PutObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("uploading to %s/%s: %w", w.bucket, w.key, err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"calmh.dev/freezebot/freeze"
+	"github.com/google/go-github/github"
+)
+
+// planKey returns the plan signing key, from the given file or else the
+// FREEZEBOT_PLAN_KEY environment variable. Without either, plans are not
+// signed.
+func planKey(path string) ([]byte, error) {
+	return readKey(path, "FREEZEBOT_PLAN_KEY")
+}
+
+// readKey returns a key from the given file, or else the environment
+// variable, or nil.
+func readKey(path, env string) ([]byte, error) {
+	if path != "" {
+		bs, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.TrimSpace(string(bs))), nil
+	}
+	if key := os.Getenv(env); key != "" {
+		return []byte(key), nil
+	}
+	return nil, nil
+}
+
+// writePlan evaluates the config and writes the plan to path, signed if we
+// have a key.
+func writePlan(ctx context.Context, r *freeze.Runner, cfg freeze.Config, path string, key []byte) error {
+	me, err := currentUser(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+
+	plan, evalErr := r.Evaluate(ctx, cfg)
+	sp := freeze.SignedPlan{Plan: plan, CreatedBy: me, CreatedAt: time.Now().UTC()}
+	if key != nil {
+		sp, err = freeze.SignPlan(plan, me, time.Now(), key)
+		if err != nil {
+			return err
+		}
+	}
+	bs, err := json.MarshalIndent(sp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(path, "s3://") || strings.HasPrefix(path, "gs://") {
+		w, err := openOutput(path, "plan.json")
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(bs); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	} else if err := ioutil.WriteFile(path, bs, 0o644); err != nil {
+		return err
+	}
+	log.Printf("Wrote plan with %d actions to %s", len(plan.Actions), path)
+	return evalErr
+}
+
+// applyPlan verifies and executes the plan in path. If distinct is set, the
+// plan must have been created by someone else than the current user. Actions
+// on issues that have changed since the plan was made are not performed.
+func applyPlan(ctx context.Context, r *freeze.Runner, cfg freeze.Config, path string, key []byte, distinct bool, profile freeze.Profile) error {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var sp freeze.SignedPlan
+	if err := json.Unmarshal(bs, &sp); err != nil {
+		return err
+	}
+	switch {
+	case sp.Signature != "" || key != nil:
+		if err := sp.Verify(key); err != nil {
+			return err
+		}
+	case distinct:
+		return errors.New("a distinct approver requires a signed plan")
+	}
+
+	me, err := currentUser(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+	if distinct && me == sp.CreatedBy {
+		return fmt.Errorf("plan was created by %s and must be approved by someone else", me)
+	}
+
+	plan, err := r.DropStale(ctx, profile.Filter(sp.Plan))
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Executing plan with %d actions created by %s at %v, approved by %s", len(plan.Actions), sp.CreatedBy, sp.CreatedAt, me)
+	return r.Execute(ctx, cfg, plan)
+}
+
+func currentUser(ctx context.Context, client *github.Client) (string, error) {
+	if appIdentity != "" {
+		// Installation tokens can't look up a user.
+		return appIdentity, nil
+	}
+	u, _, err := client.Users.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("getting current user: %w", err)
+	}
+	return u.GetLogin(), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var metricAPIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "freezebot",
+	Name:      "api_request_duration_seconds",
+	Help:      "Duration of GitHub API requests, by kind of endpoint and status",
+	Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+}, []string{"endpoint", "status"})
+
+// countingTransport counts the API requests made, for the summary at the
+// end of a run.
+type countingTransport struct {
+	next http.RoundTripper
+	n    int64
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&t.n, 1)
+	return t.next.RoundTrip(req)
+}
+
+func (t *countingTransport) count() int64 {
+	return atomic.LoadInt64(&t.n)
+}
+
+// timingTransport logs the endpoint, duration, status and rate limit cost
+// of each API request, and records the durations as metrics.
+type timingTransport struct {
+	next http.RoundTripper
+
+	mut  sync.Mutex
+	used map[string]int // rate limit resource -> last seen X-RateLimit-Used
+}
+
+func (t *timingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	d := time.Since(start)
+	kind := endpointKind(req)
+	if err != nil {
+		metricAPIDuration.WithLabelValues(kind, "error").Observe(d.Seconds())
+		log.Printf("API %s %s: %v after %.3fs", req.Method, req.URL.RequestURI(), err, d.Seconds())
+		return resp, err
+	}
+	metricAPIDuration.WithLabelValues(kind, strconv.Itoa(resp.StatusCode)).Observe(d.Seconds())
+	resource := resp.Header.Get("X-RateLimit-Resource")
+	log.Printf("API %s %s: %d in %.3fs, cost %d (%s, %s left)", req.Method, req.URL.RequestURI(), resp.StatusCode, d.Seconds(), t.cost(resource, resp.Header.Get("X-RateLimit-Used")), resource, resp.Header.Get("X-RateLimit-Remaining"))
+	return resp, nil
+}
+
+// cost returns how much the rate limit use of the resource went up since
+// the previous response. With concurrent requests it may be attributed to
+// the wrong one of them.
+func (t *timingTransport) cost(resource, used string) int {
+	n, err := strconv.Atoi(used)
+	if resource == "" || err != nil {
+		return 0
+	}
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	if t.used == nil {
+		t.used = make(map[string]int)
+	}
+	prev, ok := t.used[resource]
+	t.used[resource] = n
+	if !ok || n < prev {
+		// First seen, or the limit was reset since.
+		return 1
+	}
+	return n - prev
+}
+
+// endpointKind classifies the request as "search", "graphql", "mutation"
+// or "read", for telling where time goes.
+func endpointKind(req *http.Request) string {
+	switch {
+	case strings.Contains(req.URL.Path, "/search/"):
+		return "search"
+	case strings.HasSuffix(req.URL.Path, "/graphql"):
+		return "graphql"
+	case req.Method != http.MethodGet && req.Method != http.MethodHead:
+		return "mutation"
+	default:
+		return "read"
+	}
+}
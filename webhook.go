@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"calmh.dev/freezebot/freeze"
+	"github.com/google/go-github/github"
+)
+
+type issueRef struct {
+	owner, repo string
+	number      int
+}
+
+// serveWebhooks receives issue and issue comment webhooks on the given
+// address and evaluates the directives against the affected issue. Issues
+// are handled one at a time, in the order the events arrive.
+func serveWebhooks(ctx context.Context, r *freeze.Runner, cfg freeze.Config, listen string, secret []byte) error {
+	queue := make(chan issueRef, 1000)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		payload, err := github.ValidatePayload(req, secret)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		owner, repo, number, ok := freeze.IssueEvent(github.WebHookType(req), payload)
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		select {
+		case queue <- issueRef{owner, repo, number}:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.Error(w, "queue full", http.StatusServiceUnavailable)
+		}
+	})
+
+	srv := &http.Server{Addr: listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go func() {
+		for {
+			select {
+			case ref := <-queue:
+				if err := r.HandleIssue(ctx, cfg, ref.owner, ref.repo, ref.number); err != nil {
+					log.Printf("Handling %s/%s#%d: %v", ref.owner, ref.repo, ref.number, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	log.Printf("Listening for webhooks on %s", listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}